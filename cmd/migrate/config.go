@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	sqldb "github.com/kecci/go-toolkit/lib/sql"
+	"gopkg.in/yaml.v2"
+)
+
+// loadConfig reads the database config from file, then overrides the driver and master DSN by env and flags
+func loadConfig(file, driver, dsn string) (sqldb.DBConfig, error) {
+	var cfg sqldb.DBConfig
+	if file != "" {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read config: %w", err)
+		}
+
+		switch filepath.Ext(file) {
+		case ".yaml", ".yml":
+			err = yaml.Unmarshal(content, &cfg)
+		case ".json":
+			err = json.Unmarshal(content, &cfg)
+		default:
+			return cfg, fmt.Errorf("unsupported config file %s, must be yaml or json", file)
+		}
+		if err != nil {
+			return cfg, fmt.Errorf("failed to parse config %s: %w", file, err)
+		}
+	}
+
+	for _, v := range []string{os.Getenv("MIGRATE_DRIVER"), driver} {
+		if v != "" {
+			cfg.Driver = v
+		}
+	}
+	for _, v := range []string{os.Getenv("MIGRATE_DSN"), dsn} {
+		if v != "" {
+			cfg.MasterDSN = v
+		}
+	}
+
+	if cfg.Driver == "" || cfg.MasterDSN == "" {
+		return cfg, errors.New("driver and dsn are required, from -config, env or flags")
+	}
+
+	// migration only runs on master, and must not be served by a follower that is not connected yet
+	cfg.FollowerDSN, cfg.FollowerDSNs = "", nil
+	cfg.LazyConnect = false
+	return cfg, nil
+}
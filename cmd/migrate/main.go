@@ -0,0 +1,201 @@
+// Command migrate applies the SQL migrations of a service with lib/sql/migrate,
+// so deploy pipelines can migrate the database without a custom program per service.
+//
+// Usage:
+//
+//	migrate [flags] <command> [arg]
+//
+// Commands:
+//
+//	create <name>  create a pair of empty up and down migration files
+//	up             apply every pending migration
+//	down [N]       revert the last N applied migrations, default 1
+//	goto <V>       migrate up or down to version V, 0 reverts every migration
+//	status         print the state of every migration
+//	force <V>      record the database as migrated to version V without running any migration
+//
+// The database is read from a YAML or JSON file of lib/sql.DBConfig given by -config,
+// and the driver and master DSN can be overridden by MIGRATE_DRIVER and MIGRATE_DSN env, then by -driver and -dsn flags
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/kecci/go-toolkit/lib/log"
+	sqldb "github.com/kecci/go-toolkit/lib/sql"
+	"github.com/kecci/go-toolkit/lib/sql/migrate"
+	_ "github.com/lib/pq"
+)
+
+const usage = `Usage: migrate [flags] <command> [arg]
+
+Commands:
+  create <name>  create a pair of empty up and down migration files
+  up             apply every pending migration
+  down [N]       revert the last N applied migrations, default 1
+  goto <V>       migrate up or down to version V, 0 reverts every migration
+  status         print the state of every migration
+  force <V>      record the database as migrated to version V without running any migration
+
+Flags:
+`
+
+func main() {
+	var (
+		configFile = flag.String("config", os.Getenv("MIGRATE_CONFIG"), "YAML or JSON file of the database config, env MIGRATE_CONFIG")
+		driver     = flag.String("driver", "", "database driver like postgres or mysql, env MIGRATE_DRIVER")
+		dsn        = flag.String("dsn", "", "master DSN of the database, env MIGRATE_DSN")
+		dir        = flag.String("dir", envOrDefault("MIGRATE_DIR", "migrations"), "directory of the migration files, env MIGRATE_DIR")
+		table      = flag.String("table", envOrDefault("MIGRATE_TABLE", migrate.DefaultTable), "table to record applied migrations, env MIGRATE_TABLE")
+	)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	command, args := flag.Arg(0), flag.Args()[1:]
+
+	if command == "create" {
+		if len(args) != 1 {
+			log.Fatal("migrate: create requires the migration name")
+		}
+		if err := create(*dir, args[0], time.Now()); err != nil {
+			log.Fatalf("migrate: %s", err.Error())
+		}
+		return
+	}
+
+	cfg, err := loadConfig(*configFile, *driver, *dsn)
+	if err != nil {
+		log.Fatalf("migrate: %s", err.Error())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, cfg, *dir, *table, command, args); err != nil {
+		stop()
+		log.Fatalf("migrate: %s", err.Error())
+	}
+}
+
+func run(ctx context.Context, cfg sqldb.DBConfig, dir, table, command string, args []string) error {
+	db, err := sqldb.Connect(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer db.Close()
+
+	// the directory is the root of the file system, as fs.FS paths can't go outside of it
+	m, err := migrate.New(db, os.DirFS(dir), migrate.Config{Table: table})
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		n := 1
+		if len(args) > 0 {
+			if n, err = strconv.Atoi(args[0]); err != nil {
+				return fmt.Errorf("invalid number of migrations %q", args[0])
+			}
+		}
+		return m.Down(ctx, n)
+	case "goto":
+		version, err := versionArg(command, args)
+		if err != nil {
+			return err
+		}
+		return m.To(ctx, version)
+	case "force":
+		version, err := versionArg(command, args)
+		if err != nil {
+			return err
+		}
+		return m.Force(ctx, version)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(statuses)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q, run migrate -h for usage", command)
+	}
+}
+
+func versionArg(command string, args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("%s requires the version", command)
+	}
+	version, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid version %q", args[0])
+	}
+	return version, nil
+}
+
+// create writes empty up and down migration files, versioned by the current time
+func create(dir, name string, now time.Time) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	prefix := filepath.Join(dir, now.UTC().Format("20060102150405")+"_"+name)
+	for _, file := range []string{prefix + ".up.sql", prefix + ".down.sql"} {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		f.Close()
+		fmt.Println(file)
+	}
+	return nil
+}
+
+func printStatus(statuses []migrate.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, s := range statuses {
+		state, appliedAt := "pending", ""
+		if s.Applied {
+			state = "applied"
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		switch {
+		case s.Dirty:
+			state = "dirty"
+		case s.Edited:
+			state = "edited"
+		case s.Missing:
+			state = "missing"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	w.Flush()
+}
+
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
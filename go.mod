@@ -3,7 +3,9 @@ module github.com/kecci/go-toolkit
 go 1.16
 
 require (
+	github.com/go-sql-driver/mysql v1.5.0
 	github.com/jmoiron/sqlx v1.3.4
 	github.com/lib/pq v1.10.2
 	github.com/rs/zerolog v1.22.0
+	gopkg.in/yaml.v2 v2.4.0
 )
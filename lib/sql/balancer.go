@@ -0,0 +1,151 @@
+package sql
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// list of supported load balancer strategy for follower DB
+const (
+	BalancerRoundRobin = "round_robin"
+	BalancerRandom     = "random"
+	BalancerLeastInUse = "least_in_use"
+	BalancerWeighted   = "weighted"
+)
+
+// Balancer decides which follower DB will serve the next read operation
+type Balancer interface {
+	// Pick returns one of the given followers.
+	// followers is never empty
+	Pick(followers []*sqlx.DB) *sqlx.DB
+}
+
+// NewRoundRobinBalancer creates balancer that picks the followers in turn
+func NewRoundRobinBalancer() Balancer {
+	return &roundRobinBalancer{}
+}
+
+type roundRobinBalancer struct {
+	counter uint64
+}
+
+func (b *roundRobinBalancer) Pick(followers []*sqlx.DB) *sqlx.DB {
+	n := atomic.AddUint64(&b.counter, 1)
+	return followers[(n-1)%uint64(len(followers))]
+}
+
+// NewRandomBalancer creates balancer that picks a random follower
+func NewRandomBalancer() Balancer {
+	return randomBalancer{}
+}
+
+type randomBalancer struct{}
+
+func (randomBalancer) Pick(followers []*sqlx.DB) *sqlx.DB {
+	return followers[rand.Intn(len(followers))]
+}
+
+// NewLeastInUseBalancer creates balancer that picks the follower with the least in use connections,
+// based on sql.DBStats of each follower
+func NewLeastInUseBalancer() Balancer {
+	return leastInUseBalancer{}
+}
+
+type leastInUseBalancer struct{}
+
+func (leastInUseBalancer) Pick(followers []*sqlx.DB) *sqlx.DB {
+	picked := followers[0]
+	least := picked.Stats().InUse
+	for _, f := range followers[1:] {
+		if inUse := f.Stats().InUse; inUse < least {
+			picked, least = f, inUse
+		}
+	}
+	return picked
+}
+
+// NewWeightedBalancer creates balancer that distributes reads proportionally to the weight of each follower.
+// Follower without weight or with non positive weight is treated as having weight 1.
+//
+// It uses smooth weighted round robin, so heavier follower is not picked in bursts
+func NewWeightedBalancer(weights map[*sqlx.DB]int) Balancer {
+	return &weightedBalancer{
+		weights: weights,
+		current: make(map[*sqlx.DB]int),
+	}
+}
+
+type weightedBalancer struct {
+	mu      sync.Mutex
+	weights map[*sqlx.DB]int
+	current map[*sqlx.DB]int
+}
+
+func (b *weightedBalancer) Pick(followers []*sqlx.DB) *sqlx.DB {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var (
+		picked *sqlx.DB
+		total  int
+	)
+	for _, f := range followers {
+		w := b.weight(f)
+		total += w
+		b.current[f] += w
+		if picked == nil || b.current[f] > b.current[picked] {
+			picked = f
+		}
+	}
+	b.current[picked] -= total
+	return picked
+}
+
+func (b *weightedBalancer) weight(f *sqlx.DB) int {
+	if w := b.weights[f]; w > 0 {
+		return w
+	}
+	return 1
+}
+
+// newBalancer creates balancer by its strategy name. Empty name means round robin.
+// weights is only used by weighted balancer and follows the order of followers
+func newBalancer(name string, followers []*sqlx.DB, weights []int) (Balancer, error) {
+	if err := checkBalancer(name, len(followers), weights); err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case BalancerRandom:
+		return NewRandomBalancer(), nil
+	case BalancerLeastInUse:
+		return NewLeastInUseBalancer(), nil
+	case BalancerWeighted:
+		w := make(map[*sqlx.DB]int, len(followers))
+		for i := range weights {
+			w[followers[i]] = weights[i]
+		}
+		return NewWeightedBalancer(w), nil
+	default:
+		return NewRoundRobinBalancer(), nil
+	}
+}
+
+// checkBalancer returns error if the balancer name is unknown, or the weights don't match the number of followers
+func checkBalancer(name string, followers int, weights []int) error {
+	switch name {
+	case "", BalancerRoundRobin, BalancerRandom, BalancerLeastInUse:
+		return nil
+	case BalancerWeighted:
+		if len(weights) > 0 && len(weights) != followers {
+			return fmt.Errorf("sqldb: got %d follower weights for %d followers", len(weights), followers)
+		}
+		return nil
+	default:
+		return fmt.Errorf("sqldb: unknown load balancer %q", name)
+	}
+}
@@ -0,0 +1,52 @@
+package sql
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConnectChecksBalancerFirst(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   DBConfig
+		error string
+	}{
+		{
+			name:  "unknown balancer",
+			cfg:   DBConfig{LoadBalancer: "round-robin"},
+			error: `unknown load balancer "round-robin"`,
+		},
+		{
+			name:  "weights without follower",
+			cfg:   DBConfig{LoadBalancer: BalancerWeighted, FollowerWeights: []int{1, 2}},
+			error: "got 2 follower weights for 1 followers",
+		},
+		{
+			name:  "weights of other followers",
+			cfg:   DBConfig{LoadBalancer: BalancerWeighted, FollowerDSNs: []string{"a", "b"}, FollowerWeights: []int{1}},
+			error: "got 1 follower weights for 2 followers",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// master is unreachable, so Connect would retry until ctx is done if it connected first
+			tt.cfg.Driver = "postgres"
+			tt.cfg.MasterDSN = "postgres://127.0.0.1:1/test?sslmode=disable"
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			start := time.Now()
+			_, err := Connect(ctx, tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Fatalf("expected error containing %q, got %v", tt.error, err)
+			}
+			if time.Since(start) > time.Second {
+				t.Errorf("expected Connect to fail before connecting, took %s", time.Since(start))
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/kecci/go-toolkit/lib/sql/internal/sqlutil"
+)
+
+// bindLimits is the maximum number of bind parameters in a single statement of each driver
+var bindLimits = map[string]int{
+	"postgres":  65535,
+	"mysql":     65535,
+	"sqlite3":   999,
+	"sqlserver": 2100,
+	"mssql":     2100,
+}
+
+// defaultBindLimit is used for driver not listed in bindLimits
+const defaultBindLimit = 999
+
+// BatchOptions for BatchInsert and BatchUpsert
+type BatchOptions struct {
+	// Columns to insert. Default to every db tagged field of the struct, including embedded struct fields
+	Columns []string
+
+	// ConflictColumns are the columns of the unique constraint checked by BatchUpsert.
+	// Required for postgres and sqlite3, mysql checks every unique key
+	ConflictColumns []string
+
+	// UpdateColumns are the columns updated by BatchUpsert when the row already exists.
+	// Default to every inserted column except ConflictColumns
+	UpdateColumns []string
+
+	// BatchSize is the maximum number of rows in a single statement.
+	// Default to as many rows as the bind parameter limit of the driver allows
+	BatchSize int
+
+	// InTx executes every statement in one transaction, so either every row is written or none.
+	// If ctx already carries a transaction, the statements always join it
+	InTx bool
+}
+
+// BatchInsert inserts rows into table with multi-row INSERT statements on master, and returns the number of rows inserted.
+// rows must be a slice of structs or pointers to struct, mapped to the columns by their db tag.
+// The rows are split into several statements so the bind parameter limit of the driver is never exceeded
+func (db *DB) BatchInsert(ctx context.Context, table string, rows interface{}, opts BatchOptions) (int64, error) {
+	return db.batchExec(ctx, table, rows, opts, false)
+}
+
+// BatchUpsert inserts rows into table like BatchInsert, and updates the existing rows instead
+// with ON CONFLICT DO UPDATE on postgres and sqlite3, or ON DUPLICATE KEY UPDATE on mysql.
+//
+// It returns the rows affected reported by the driver. MySQL counts an updated row as 2 rows affected
+func (db *DB) BatchUpsert(ctx context.Context, table string, rows interface{}, opts BatchOptions) (int64, error) {
+	return db.batchExec(ctx, table, rows, opts, true)
+}
+
+func (db *DB) batchExec(ctx context.Context, table string, rows interface{}, opts BatchOptions, upsert bool) (int64, error) {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return 0, fmt.Errorf("sqldb: rows must be a slice, got %T", rows)
+	}
+	if v.Len() == 0 {
+		return 0, nil
+	}
+
+	columns := opts.Columns
+	if len(columns) == 0 {
+		columns = sqlutil.StructColumns(db.master.Mapper, v.Type().Elem())
+		if len(columns) == 0 {
+			return 0, fmt.Errorf("sqldb: no column found in %s", v.Type().Elem())
+		}
+	}
+	toValues, err := rowValuesFunc(db.master.Mapper, v.Type().Elem(), columns)
+	if err != nil {
+		return 0, err
+	}
+
+	var suffix string
+	if upsert {
+		if suffix, err = db.upsertClause(columns, opts.ConflictColumns, opts.UpdateColumns); err != nil {
+			return 0, err
+		}
+	}
+
+	size := db.batchSize(len(columns), opts.BatchSize)
+	run := func(ctx context.Context) (int64, error) {
+		var affected int64
+		for start := 0; start < v.Len(); start += size {
+			end := start + size
+			if end > v.Len() {
+				end = v.Len()
+			}
+
+			args := make([]interface{}, 0, (end-start)*len(columns))
+			for i := start; i < end; i++ {
+				values, err := toValues(v.Index(i))
+				if err != nil {
+					return affected, err
+				}
+				args = append(args, values...)
+			}
+
+			query := db.Rebind(insertQuery(table, columns, end-start) + suffix)
+			result, err := db.Master.ExecContext(ctx, query, args...)
+			if err != nil {
+				return affected, err
+			}
+			n, err := result.RowsAffected()
+			if err != nil {
+				return affected, err
+			}
+			affected += n
+		}
+		return affected, nil
+	}
+
+	if !opts.InTx {
+		return run(ctx)
+	}
+
+	var affected int64
+	err = db.RunInTx(ctx, nil, func(ctx context.Context) (err error) {
+		affected, err = run(ctx)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+// batchSize returns the number of rows in a single statement, within the bind parameter limit of the driver
+func (db *DB) batchSize(columns, requested int) int {
+	limit, ok := bindLimits[db.driver]
+	if !ok {
+		limit = defaultBindLimit
+	}
+
+	size := limit / columns
+	if requested > 0 && requested < size {
+		size = requested
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// upsertClause returns the clause appended to the INSERT statement to update the existing rows
+func (db *DB) upsertClause(columns, conflictColumns, updateColumns []string) (string, error) {
+	if len(updateColumns) == 0 {
+		conflicts := make(map[string]bool, len(conflictColumns))
+		for _, c := range conflictColumns {
+			conflicts[c] = true
+		}
+		for _, c := range columns {
+			if !conflicts[c] {
+				updateColumns = append(updateColumns, c)
+			}
+		}
+	}
+
+	var b strings.Builder
+	switch db.driver {
+	case "postgres", "sqlite3":
+		if len(conflictColumns) == 0 {
+			return "", fmt.Errorf("sqldb: upsert on %s requires the conflict columns", db.driver)
+		}
+		b.WriteString(" ON CONFLICT (" + strings.Join(conflictColumns, ", ") + ")")
+		if len(updateColumns) == 0 {
+			b.WriteString(" DO NOTHING")
+			return b.String(), nil
+		}
+		b.WriteString(" DO UPDATE SET ")
+		for i, c := range updateColumns {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(c + " = EXCLUDED." + c)
+		}
+	case "mysql":
+		b.WriteString(" ON DUPLICATE KEY UPDATE ")
+		if len(updateColumns) == 0 {
+			// updating a column to itself keeps the existing row as is
+			b.WriteString(columns[0] + " = " + columns[0])
+			return b.String(), nil
+		}
+		for i, c := range updateColumns {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(c + " = VALUES(" + c + ")")
+		}
+	default:
+		return "", fmt.Errorf("sqldb: upsert is not supported for driver %s", db.driver)
+	}
+	return b.String(), nil
+}
+
+// insertQuery returns multi-row INSERT statement of n rows with ? placeholders
+func insertQuery(table string, columns []string, n int) string {
+	row := "(" + sqlutil.Placeholders(len(columns)) + ")"
+
+	var b strings.Builder
+	b.Grow(len(table) + len(row)*n + 64)
+	b.WriteString("INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES ")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(row)
+	}
+	return b.String()
+}
@@ -0,0 +1,136 @@
+package sql_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	sqldb "github.com/kecci/go-toolkit/lib/sql"
+	"github.com/kecci/go-toolkit/lib/sql/sqltest"
+)
+
+type batchUser struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+	Age  int    `db:"age"`
+}
+
+func batchUsers(n int) []batchUser {
+	users := make([]batchUser, n)
+	for i := range users {
+		users[i] = batchUser{ID: int64(i + 1), Name: "user", Age: 20}
+	}
+	return users
+}
+
+// questionRows returns n rows of ? placeholders of the columns, like "(?, ?), (?, ?)"
+func questionRows(n, columns int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", columns), ", ") + ")"
+	return strings.TrimSuffix(strings.Repeat(row+", ", n), ", ")
+}
+
+func TestBatchInsertBatchSize(t *testing.T) {
+	db, mock := sqltest.NewDB(t, "postgres")
+	mock.ExpectExec(sqltest.Exact("INSERT INTO users (id, name, age) VALUES ($1, $2, $3), ($4, $5, $6)")).
+		WithArgs(1, "user", 20, 2, "user", 20).
+		WillReturnResult(sqltest.NewResult(0, 2))
+	mock.ExpectExec(sqltest.Exact("INSERT INTO users (id, name, age) VALUES ($1, $2, $3), ($4, $5, $6)")).
+		WithArgs(3, "user", 20, 4, "user", 20).
+		WillReturnResult(sqltest.NewResult(0, 2))
+	mock.ExpectExec(sqltest.Exact("INSERT INTO users (id, name, age) VALUES ($1, $2, $3)")).
+		WithArgs(5, "user", 20).
+		WillReturnResult(sqltest.NewResult(0, 1))
+
+	n, err := db.BatchInsert(context.Background(), "users", batchUsers(5), sqldb.BatchOptions{BatchSize: 2})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if n != 5 {
+		t.Errorf("expected 5 rows inserted, got %d", n)
+	}
+}
+
+func TestBatchInsertBindLimit(t *testing.T) {
+	// sqlite3 allows 999 bind parameters, so 333 rows of 3 columns per statement
+	db, mock := sqltest.NewDB(t, "sqlite3")
+	mock.ExpectExec(sqltest.Exact("INSERT INTO users (id, name, age) VALUES " + questionRows(333, 3)))
+	mock.ExpectExec(sqltest.Exact("INSERT INTO users (id, name, age) VALUES " + questionRows(1, 3))).WithArgs(334, "user", 20)
+
+	if _, err := db.BatchInsert(context.Background(), "users", batchUsers(334), sqldb.BatchOptions{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestBatchInsertColumnsInTx(t *testing.T) {
+	db, mock := sqltest.NewDB(t, "mysql")
+	mock.ExpectBegin()
+	mock.ExpectExec(sqltest.Exact("INSERT INTO users (id, name) VALUES (?, ?), (?, ?)")).WithArgs(1, "user", 2, "user")
+	mock.ExpectCommit()
+
+	opts := sqldb.BatchOptions{Columns: []string{"id", "name"}, InTx: true}
+	if _, err := db.BatchInsert(context.Background(), "users", batchUsers(2), opts); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestBatchUpsert(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver string
+		opts   sqldb.BatchOptions
+		query  string
+	}{
+		{
+			name:   "postgres",
+			driver: "postgres",
+			opts:   sqldb.BatchOptions{ConflictColumns: []string{"id"}},
+			query:  "INSERT INTO users (id, name, age) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, age = EXCLUDED.age",
+		},
+		{
+			name:   "postgres update columns",
+			driver: "postgres",
+			opts:   sqldb.BatchOptions{ConflictColumns: []string{"id"}, UpdateColumns: []string{"age"}},
+			query:  "INSERT INTO users (id, name, age) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET age = EXCLUDED.age",
+		},
+		{
+			name:   "postgres nothing to update",
+			driver: "postgres",
+			opts:   sqldb.BatchOptions{Columns: []string{"id"}, ConflictColumns: []string{"id"}},
+			query:  "INSERT INTO users (id) VALUES ($1) ON CONFLICT (id) DO NOTHING",
+		},
+		{
+			name:   "mysql",
+			driver: "mysql",
+			query:  "INSERT INTO users (id, name, age) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE id = VALUES(id), name = VALUES(name), age = VALUES(age)",
+		},
+		{
+			name:   "mysql update columns",
+			driver: "mysql",
+			opts:   sqldb.BatchOptions{UpdateColumns: []string{"name"}},
+			query:  "INSERT INTO users (id, name, age) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE name = VALUES(name)",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := sqltest.NewDB(t, tt.driver)
+			mock.ExpectExec(sqltest.Exact(tt.query)).WillReturnResult(sqltest.NewResult(0, 1))
+
+			n, err := db.BatchUpsert(context.Background(), "users", batchUsers(1), tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if n != 1 {
+				t.Errorf("expected 1 row affected, got %d", n)
+			}
+		})
+	}
+}
+
+func TestBatchUpsertRequiresConflictColumns(t *testing.T) {
+	db, _ := sqltest.NewDB(t, "postgres")
+	if _, err := db.BatchUpsert(context.Background(), "users", batchUsers(1), sqldb.BatchOptions{}); err == nil {
+		t.Error("expected error without conflict columns on postgres")
+	}
+}
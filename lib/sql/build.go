@@ -0,0 +1,52 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Builder builds a query and its arguments, like the statements of lib/sql/builder.
+// The query must use the placeholder of the DB driver.
+//
+// DB runs Builder with ExecBuilder, SelectBuilder and GetBuilder,
+// as Exec, Select and Get of DB are the sqlx style methods of Master and Follower
+type Builder interface {
+	Build() (query string, args []interface{}, err error)
+}
+
+// ExecBuilder builds the query of b and executes it on master
+func (db *DB) ExecBuilder(ctx context.Context, b Builder) (sql.Result, error) {
+	query, args, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return db.Master.ExecContext(ctx, query, args...)
+}
+
+// SelectBuilder builds the query of b and selects the rows into dest from follower, like Follower.SelectContext
+func (db *DB) SelectBuilder(ctx context.Context, dest interface{}, b Builder) error {
+	query, args, err := b.Build()
+	if err != nil {
+		return err
+	}
+	return db.Follower.SelectContext(ctx, dest, query, args...)
+}
+
+// GetBuilder builds the query of b and gets a single row into dest from follower, like Follower.GetContext
+func (db *DB) GetBuilder(ctx context.Context, dest interface{}, b Builder) error {
+	query, args, err := b.Build()
+	if err != nil {
+		return err
+	}
+	return db.Follower.GetContext(ctx, dest, query, args...)
+}
+
+// ExecBuilderReturning builds the write query of b returning rows, like INSERT or UPDATE with RETURNING,
+// and executes it on master like ExecReturning
+func (db *DB) ExecBuilderReturning(ctx context.Context, dest interface{}, b Builder) error {
+	query, args, err := b.Build()
+	if err != nil {
+		return err
+	}
+	return db.ExecReturning(ctx, dest, query, args...)
+}
@@ -0,0 +1,127 @@
+// Package builder composes SELECT, INSERT, UPDATE and DELETE statements with the placeholder of the database driver.
+// Values are always passed as arguments, so dynamic filters never need string concatenation:
+//
+//	b := builder.New(db.DriverName())
+//
+//	q := b.Select("id", "name").From("users").Where("deleted_at IS NULL")
+//	if filter.Name != "" {
+//		q.Where("name = ?", filter.Name)
+//	}
+//	if len(filter.IDs) > 0 {
+//		q.Where("id IN (?)", filter.IDs)
+//	}
+//	q.OrderBy("id DESC").Limit(20)
+//
+//	err := db.SelectBuilder(ctx, &users, q)
+//
+// Conditions are written with ? placeholders, which are converted to the placeholder of the driver by Build.
+// Slice argument of a condition is expanded to one placeholder per element, for IN condition.
+// UPDATE and DELETE without condition fail with ErrNoCondition, unless All is called.
+// Table and column names are written to the query as is, and must never come from user input
+package builder
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	sqldb "github.com/kecci/go-toolkit/lib/sql"
+)
+
+// every statement can be run by lib/sql DB
+var (
+	_ sqldb.Builder = (*SelectStatement)(nil)
+	_ sqldb.Builder = (*InsertStatement)(nil)
+	_ sqldb.Builder = (*UpdateStatement)(nil)
+	_ sqldb.Builder = (*DeleteStatement)(nil)
+)
+
+// ErrNoCondition is returned by Build of UPDATE or DELETE statement without any condition,
+// so a filter that is empty by accident never writes the whole table. Call All to write every row on purpose
+var ErrNoCondition = errors.New("builder: update or delete without condition, call All to write every row")
+
+// Builder creates statements for a database driver
+type Builder struct {
+	driver string
+}
+
+// New creates Builder for driverName, like postgres or mysql. Use DriverName of lib/sql DB
+func New(driverName string) *Builder {
+	return &Builder{driver: driverName}
+}
+
+// Select starts SELECT statement of the columns
+func (b *Builder) Select(columns ...string) *SelectStatement {
+	return &SelectStatement{builder: b, columns: columns}
+}
+
+// InsertInto starts INSERT statement into table
+func (b *Builder) InsertInto(table string) *InsertStatement {
+	return &InsertStatement{builder: b, table: table}
+}
+
+// Update starts UPDATE statement of table
+func (b *Builder) Update(table string) *UpdateStatement {
+	return &UpdateStatement{builder: b, table: table}
+}
+
+// DeleteFrom starts DELETE statement from table
+func (b *Builder) DeleteFrom(table string) *DeleteStatement {
+	return &DeleteStatement{builder: b, table: table}
+}
+
+// finish converts the ? placeholders to the placeholder of the driver
+func (b *Builder) finish(query string, args []interface{}) (string, []interface{}, error) {
+	return sqlx.Rebind(sqlx.BindType(b.driver), query), args, nil
+}
+
+// supportsReturning reports whether the driver supports RETURNING clause
+func (b *Builder) supportsReturning() bool {
+	return b.driver == "postgres" || b.driver == "sqlite3"
+}
+
+// expand expands the slice arguments of a condition to one placeholder per element, for IN condition.
+// Only condition arguments are expanded, values of SET and VALUES are bound as they are
+func expand(cond string, args []interface{}) (string, []interface{}, error) {
+	if len(args) == 0 {
+		return cond, args, nil
+	}
+	return sqlx.In(cond, args...)
+}
+
+// condition is an expression with ? placeholders of args
+type condition struct {
+	expr string
+	args []interface{}
+}
+
+// conditions is a list of conditions joined with AND
+type conditions []condition
+
+func (c *conditions) add(cond string, args []interface{}) {
+	*c = append(*c, condition{expr: cond, args: args})
+}
+
+// write writes the conditions after keyword, every condition is wrapped in parentheses so OR inside it stays in it
+func (c conditions) write(sb *strings.Builder, args *[]interface{}, keyword string) error {
+	if len(c) == 0 {
+		return nil
+	}
+	sb.WriteString(" " + keyword + " ")
+	for i, cond := range c {
+		expr, condArgs, err := expand(cond.expr, cond.args)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			sb.WriteString(" AND ")
+		}
+		if len(c) > 1 {
+			sb.WriteString("(" + expr + ")")
+		} else {
+			sb.WriteString(expr)
+		}
+		*args = append(*args, condArgs...)
+	}
+	return nil
+}
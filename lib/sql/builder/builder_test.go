@@ -0,0 +1,105 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+)
+
+type statement interface {
+	Build() (string, []interface{}, error)
+}
+
+func TestBuild(t *testing.T) {
+	pg := New("postgres")
+	my := New("mysql")
+
+	tests := []struct {
+		name  string
+		stmt  statement
+		query string
+		args  []interface{}
+	}{
+		{
+			name:  "postgres placeholders",
+			stmt:  pg.Select("id").From("users").Where("name = ?", "a").Where("age > ?", 20),
+			query: "SELECT id FROM users WHERE (name = $1) AND (age > $2)",
+			args:  []interface{}{"a", 20},
+		},
+		{
+			name:  "mysql placeholders",
+			stmt:  my.Select("id").From("users").Where("name = ?", "a").Where("age > ?", 20),
+			query: "SELECT id FROM users WHERE (name = ?) AND (age > ?)",
+			args:  []interface{}{"a", 20},
+		},
+		{
+			name:  "IN condition is expanded",
+			stmt:  pg.Select("id").From("users").Where("id IN (?)", []int{1, 2, 3}).Where("name = ?", "a"),
+			query: "SELECT id FROM users WHERE (id IN ($1, $2, $3)) AND (name = $4)",
+			args:  []interface{}{1, 2, 3, "a"},
+		},
+		{
+			name:  "IN condition of join is expanded",
+			stmt:  pg.Select("u.id").From("users u").Join("roles r", "r.user_id = u.id AND r.name IN (?)", []string{"a", "b"}).Where("u.id = ?", 1),
+			query: "SELECT u.id FROM users u JOIN roles r ON r.user_id = u.id AND r.name IN ($1, $2) WHERE u.id = $3",
+			args:  []interface{}{"a", "b", 1},
+		},
+		{
+			name:  "VALUES slice is bound as is",
+			stmt:  pg.InsertInto("users").Columns("id", "tags").Values(1, []string{"a", "b"}),
+			query: "INSERT INTO users (id, tags) VALUES ($1, $2)",
+			args:  []interface{}{1, []string{"a", "b"}},
+		},
+		{
+			name:  "SET slice is bound as is while condition is expanded",
+			stmt:  pg.Update("users").Set("tags", []string{"a", "b"}).Where("id IN (?)", []int{1, 2}),
+			query: "UPDATE users SET tags = $1 WHERE id IN ($2, $3)",
+			args:  []interface{}{[]string{"a", "b"}, 1, 2},
+		},
+		{
+			name:  "delete with condition",
+			stmt:  my.DeleteFrom("users").Where("id IN (?)", []int{1, 2}),
+			query: "DELETE FROM users WHERE id IN (?, ?)",
+			args:  []interface{}{1, 2},
+		},
+		{
+			name:  "update of every row",
+			stmt:  pg.Update("users").Set("active", false).All(),
+			query: "UPDATE users SET active = $1",
+			args:  []interface{}{false},
+		},
+		{
+			name:  "delete of every row",
+			stmt:  pg.DeleteFrom("users").All(),
+			query: "DELETE FROM users",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := tt.stmt.Build()
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if query != tt.query {
+				t.Errorf("expected query %q, got %q", tt.query, query)
+			}
+			if len(args) != 0 || len(tt.args) != 0 {
+				if !reflect.DeepEqual(args, tt.args) {
+					t.Errorf("expected args %v, got %v", tt.args, args)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildNoCondition(t *testing.T) {
+	b := New("postgres")
+
+	if _, _, err := b.Update("users").Set("active", false).Build(); err != ErrNoCondition {
+		t.Errorf("expected ErrNoCondition for update, got %v", err)
+	}
+	if _, _, err := b.DeleteFrom("users").Build(); err != ErrNoCondition {
+		t.Errorf("expected ErrNoCondition for delete, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package builder
+
+import (
+	"errors"
+	"strings"
+)
+
+// DeleteStatement is DELETE statement, built by Build
+type DeleteStatement struct {
+	builder *Builder
+	table   string
+	where   conditions
+	all     bool
+}
+
+// Where adds condition with ? placeholders of args. Multiple conditions are joined with AND
+func (s *DeleteStatement) Where(cond string, args ...interface{}) *DeleteStatement {
+	s.where.add(cond, args)
+	return s
+}
+
+// All allows the statement to run without condition, writing every row of the table
+func (s *DeleteStatement) All() *DeleteStatement {
+	s.all = true
+	return s
+}
+
+// Build returns the query with the placeholder of the driver and its arguments
+func (s *DeleteStatement) Build() (string, []interface{}, error) {
+	if s.table == "" {
+		return "", nil, errors.New("builder: delete requires the table")
+	}
+
+	if len(s.where) == 0 && !s.all {
+		return "", nil, ErrNoCondition
+	}
+
+	var sb strings.Builder
+	var args []interface{}
+
+	sb.WriteString("DELETE FROM " + s.table)
+	if err := s.where.write(&sb, &args, "WHERE"); err != nil {
+		return "", nil, err
+	}
+	return s.builder.finish(sb.String(), args)
+}
@@ -0,0 +1,71 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kecci/go-toolkit/lib/sql/internal/sqlutil"
+)
+
+// InsertStatement is INSERT statement, built by Build
+type InsertStatement struct {
+	builder   *Builder
+	table     string
+	columns   []string
+	rows      [][]interface{}
+	returning []string
+}
+
+// Columns sets the columns to insert
+func (s *InsertStatement) Columns(columns ...string) *InsertStatement {
+	s.columns = columns
+	return s
+}
+
+// Values adds a row to insert, values must follow the order of the columns
+func (s *InsertStatement) Values(values ...interface{}) *InsertStatement {
+	s.rows = append(s.rows, values)
+	return s
+}
+
+// Returning returns the columns of the inserted rows, only supported by postgres and sqlite3.
+// Run it with ExecBuilderReturning of DB
+func (s *InsertStatement) Returning(columns ...string) *InsertStatement {
+	s.returning = columns
+	return s
+}
+
+// Build returns the query with the placeholder of the driver and its arguments
+func (s *InsertStatement) Build() (string, []interface{}, error) {
+	if s.table == "" || len(s.columns) == 0 {
+		return "", nil, errors.New("builder: insert requires the table and columns")
+	}
+	if len(s.rows) == 0 {
+		return "", nil, errors.New("builder: insert requires at least one row")
+	}
+	if len(s.returning) > 0 && !s.builder.supportsReturning() {
+		return "", nil, fmt.Errorf("builder: returning is not supported for driver %s", s.builder.driver)
+	}
+
+	var sb strings.Builder
+	args := make([]interface{}, 0, len(s.rows)*len(s.columns))
+
+	sb.WriteString("INSERT INTO " + s.table + " (" + strings.Join(s.columns, ", ") + ") VALUES ")
+	row := "(" + sqlutil.Placeholders(len(s.columns)) + ")"
+	for i, values := range s.rows {
+		if len(values) != len(s.columns) {
+			return "", nil, fmt.Errorf("builder: row %d has %d values for %d columns", i, len(values), len(s.columns))
+		}
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(row)
+		args = append(args, values...)
+	}
+
+	if len(s.returning) > 0 {
+		sb.WriteString(" RETURNING " + strings.Join(s.returning, ", "))
+	}
+	return s.builder.finish(sb.String(), args)
+}
@@ -0,0 +1,181 @@
+package builder
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/kecci/go-toolkit/lib/sql/internal/sqlutil"
+)
+
+// SelectStatement is SELECT statement, built by Build
+type SelectStatement struct {
+	builder  *Builder
+	distinct bool
+	columns  []string
+	from     string
+	fromArgs []interface{}
+
+	joins []condition
+
+	where   conditions
+	groupBy []string
+	having  conditions
+	orderBy []string
+
+	limit  int
+	offset int
+}
+
+// Distinct selects only distinct rows
+func (s *SelectStatement) Distinct() *SelectStatement {
+	s.distinct = true
+	return s
+}
+
+// From sets the table to select from. It can be a subquery with ? placeholders of args
+func (s *SelectStatement) From(table string, args ...interface{}) *SelectStatement {
+	s.from = table
+	s.fromArgs = args
+	return s
+}
+
+// Join adds INNER JOIN of table on condition
+func (s *SelectStatement) Join(table, on string, args ...interface{}) *SelectStatement {
+	return s.join("JOIN", table, on, args)
+}
+
+// LeftJoin adds LEFT JOIN of table on condition
+func (s *SelectStatement) LeftJoin(table, on string, args ...interface{}) *SelectStatement {
+	return s.join("LEFT JOIN", table, on, args)
+}
+
+// RightJoin adds RIGHT JOIN of table on condition
+func (s *SelectStatement) RightJoin(table, on string, args ...interface{}) *SelectStatement {
+	return s.join("RIGHT JOIN", table, on, args)
+}
+
+func (s *SelectStatement) join(kind, table, on string, args []interface{}) *SelectStatement {
+	s.joins = append(s.joins, condition{expr: kind + " " + table + " ON " + on, args: args})
+	return s
+}
+
+// Where adds condition with ? placeholders of args. Multiple conditions are joined with AND
+func (s *SelectStatement) Where(cond string, args ...interface{}) *SelectStatement {
+	s.where.add(cond, args)
+	return s
+}
+
+// GroupBy adds GROUP BY columns
+func (s *SelectStatement) GroupBy(columns ...string) *SelectStatement {
+	s.groupBy = append(s.groupBy, columns...)
+	return s
+}
+
+// Having adds HAVING condition with ? placeholders of args. Multiple conditions are joined with AND
+func (s *SelectStatement) Having(cond string, args ...interface{}) *SelectStatement {
+	s.having.add(cond, args)
+	return s
+}
+
+// OrderBy adds ORDER BY expressions, like "created_at DESC"
+func (s *SelectStatement) OrderBy(exprs ...string) *SelectStatement {
+	s.orderBy = append(s.orderBy, exprs...)
+	return s
+}
+
+// Limit sets the maximum number of rows, zero means no limit
+func (s *SelectStatement) Limit(n int) *SelectStatement {
+	s.limit = n
+	return s
+}
+
+// Offset sets the number of rows to skip
+func (s *SelectStatement) Offset(n int) *SelectStatement {
+	s.offset = n
+	return s
+}
+
+// Build returns the query with the placeholder of the driver and its arguments
+func (s *SelectStatement) Build() (string, []interface{}, error) {
+	if s.from == "" {
+		return "", nil, errors.New("builder: select requires the table")
+	}
+	if s.limit < 0 || s.offset < 0 {
+		return "", nil, errors.New("builder: limit and offset must not be negative")
+	}
+
+	var sb strings.Builder
+	var args []interface{}
+
+	sb.WriteString("SELECT ")
+	if s.distinct {
+		sb.WriteString("DISTINCT ")
+	}
+	if len(s.columns) == 0 {
+		sb.WriteString("*")
+	} else {
+		sb.WriteString(strings.Join(s.columns, ", "))
+	}
+
+	from, fromArgs, err := expand(s.from, s.fromArgs)
+	if err != nil {
+		return "", nil, err
+	}
+	sb.WriteString(" FROM " + from)
+	args = append(args, fromArgs...)
+	for _, join := range s.joins {
+		expr, joinArgs, err := expand(join.expr, join.args)
+		if err != nil {
+			return "", nil, err
+		}
+		sb.WriteString(" " + expr)
+		args = append(args, joinArgs...)
+	}
+
+	if err := s.where.write(&sb, &args, "WHERE"); err != nil {
+		return "", nil, err
+	}
+	if len(s.groupBy) > 0 {
+		sb.WriteString(" GROUP BY " + strings.Join(s.groupBy, ", "))
+	}
+	if err := s.having.write(&sb, &args, "HAVING"); err != nil {
+		return "", nil, err
+	}
+	if len(s.orderBy) > 0 {
+		sb.WriteString(" ORDER BY " + strings.Join(s.orderBy, ", "))
+	}
+
+	if err := s.writeLimit(&sb); err != nil {
+		return "", nil, err
+	}
+	return s.builder.finish(sb.String(), args)
+}
+
+func (s *SelectStatement) writeLimit(sb *strings.Builder) error {
+	if s.limit == 0 && s.offset == 0 {
+		return nil
+	}
+
+	if sqlutil.IsSQLServer(s.builder.driver) {
+		if len(s.orderBy) == 0 {
+			return errors.New("builder: limit and offset on sqlserver require order by")
+		}
+		sb.WriteString(" OFFSET " + strconv.Itoa(s.offset) + " ROWS")
+		if s.limit > 0 {
+			sb.WriteString(" FETCH NEXT " + strconv.Itoa(s.limit) + " ROWS ONLY")
+		}
+		return nil
+	}
+
+	if s.limit > 0 {
+		sb.WriteString(" LIMIT " + strconv.Itoa(s.limit))
+	} else if s.builder.driver == "mysql" {
+		// mysql doesn't support OFFSET without LIMIT, the largest value means no limit
+		sb.WriteString(" LIMIT 18446744073709551615")
+	}
+	if s.offset > 0 {
+		sb.WriteString(" OFFSET " + strconv.Itoa(s.offset))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// UpdateStatement is UPDATE statement, built by Build
+type UpdateStatement struct {
+	builder   *Builder
+	table     string
+	sets      []string
+	setArgs   []interface{}
+	where     conditions
+	all       bool
+	returning []string
+}
+
+// Set sets column to value
+func (s *UpdateStatement) Set(column string, value interface{}) *UpdateStatement {
+	s.sets = append(s.sets, column+" = ?")
+	s.setArgs = append(s.setArgs, value)
+	return s
+}
+
+// SetExpr sets column to expression with ? placeholders of args, like "count + ?"
+func (s *UpdateStatement) SetExpr(column, expr string, args ...interface{}) *UpdateStatement {
+	s.sets = append(s.sets, column+" = "+expr)
+	s.setArgs = append(s.setArgs, args...)
+	return s
+}
+
+// Where adds condition with ? placeholders of args. Multiple conditions are joined with AND
+func (s *UpdateStatement) Where(cond string, args ...interface{}) *UpdateStatement {
+	s.where.add(cond, args)
+	return s
+}
+
+// Returning returns the columns of the updated rows, only supported by postgres and sqlite3.
+// Run it with ExecBuilderReturning of DB
+func (s *UpdateStatement) Returning(columns ...string) *UpdateStatement {
+	s.returning = columns
+	return s
+}
+
+// All allows the statement to run without condition, writing every row of the table
+func (s *UpdateStatement) All() *UpdateStatement {
+	s.all = true
+	return s
+}
+
+// Build returns the query with the placeholder of the driver and its arguments
+func (s *UpdateStatement) Build() (string, []interface{}, error) {
+	if s.table == "" || len(s.sets) == 0 {
+		return "", nil, errors.New("builder: update requires the table and at least one column to set")
+	}
+	if len(s.returning) > 0 && !s.builder.supportsReturning() {
+		return "", nil, fmt.Errorf("builder: returning is not supported for driver %s", s.builder.driver)
+	}
+
+	if len(s.where) == 0 && !s.all {
+		return "", nil, ErrNoCondition
+	}
+
+	var sb strings.Builder
+	var args []interface{}
+
+	sb.WriteString("UPDATE " + s.table + " SET " + strings.Join(s.sets, ", "))
+	args = append(args, s.setArgs...)
+	if err := s.where.write(&sb, &args, "WHERE"); err != nil {
+		return "", nil, err
+	}
+
+	if len(s.returning) > 0 {
+		sb.WriteString(" RETURNING " + strings.Join(s.returning, ", "))
+	}
+	return s.builder.finish(sb.String(), args)
+}
@@ -0,0 +1,34 @@
+package sql
+
+import "context"
+
+type primaryContextKey struct{}
+
+// WithPrimary returns a copy of ctx that makes every Follower context method and PrepareRead
+// to be executed on master DB instead.
+//
+// Use it to read your own writes, when the replication lag of follower is not acceptable
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+// IsPrimary reports whether ctx is marked by WithPrimary
+func IsPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryContextKey{}).(bool)
+	return primary
+}
+
+type txContextKey struct{}
+
+// ContextWithTx returns a copy of ctx that carries tx.
+// Every Master and Follower context method and prepared statement of DB called with the returned context
+// is executed in tx
+func ContextWithTx(ctx context.Context, tx *Tx) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// TxFromContext returns the transaction carried by ctx, if any
+func TxFromContext(ctx context.Context) (*Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*Tx)
+	return tx, ok && tx != nil
+}
@@ -0,0 +1,190 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx/reflectx"
+	"github.com/lib/pq"
+)
+
+// CopySource is an iterator of rows given to CopyFrom
+type CopySource interface {
+	// Next advances to the next row, and returns false when there is no row left or an error occurred
+	Next() bool
+
+	// Values returns the values of the current row, in the order of the columns
+	Values() ([]interface{}, error)
+
+	// Err returns the error that stopped the iteration, if any
+	Err() error
+}
+
+// CopyFrom inserts rows into table with postgres COPY, which is much faster than inserting row by row.
+// It returns the number of rows inserted.
+//
+// rows can be one of:
+//   - slice of structs or pointers to struct, mapped to the columns by their db tag
+//   - slice of []interface{}, in the order of the columns
+//   - CopySource
+//   - channel of structs, pointers to struct or []interface{}, read until it is closed
+//
+// CopyFrom stops reading the channel once it returns an error, including cancellation of ctx,
+// so the producer must stop sending too, e.g. by also selecting on ctx.Done(), or it blocks forever.
+//
+// The rows are streamed inside a transaction, so either every row is inserted or none.
+// If ctx carries a transaction, the rows are inserted in a nested transaction of it instead.
+// Only supported for postgres
+func (db *DB) CopyFrom(ctx context.Context, table string, columns []string, rows interface{}) (int64, error) {
+	if db.driver != "postgres" {
+		return 0, fmt.Errorf("sqldb: CopyFrom is not supported for driver %s", db.driver)
+	}
+	if len(columns) == 0 {
+		return 0, errors.New("sqldb: CopyFrom requires at least one column")
+	}
+	next, err := db.copyRows(ctx, columns, rows)
+	if err != nil {
+		return 0, err
+	}
+
+	var query string
+	if i := strings.IndexByte(table, '.'); i >= 0 {
+		query = pq.CopyInSchema(table[:i], table[i+1:], columns...)
+	} else {
+		query = pq.CopyIn(table, columns...)
+	}
+
+	var count int64
+	err = db.runTx(ctx, nil, func(ctx context.Context, tx *Tx) (err error) {
+		ctx, done, ok := db.startOp(ContextWithTx(ctx, tx), db.master.queryInfo(OpCopy, query, nil))
+		if !ok {
+			return ErrClosed
+		}
+		defer func() { done(err) }()
+
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for {
+			values, ok, err := next()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			if _, err := stmt.ExecContext(ctx, values...); err != nil {
+				return err
+			}
+			count++
+		}
+
+		// exec without values flushes the buffered rows and completes the COPY
+		_, err = stmt.ExecContext(ctx)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// copyRows returns the function reading the next row of rows, see CopyFrom
+func (db *DB) copyRows(ctx context.Context, columns []string, rows interface{}) (func() ([]interface{}, bool, error), error) {
+	if src, ok := rows.(CopySource); ok {
+		return func() ([]interface{}, bool, error) {
+			if !src.Next() {
+				return nil, false, src.Err()
+			}
+			values, err := src.Values()
+			return values, err == nil, err
+		}, nil
+	}
+
+	v := reflect.ValueOf(rows)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		toValues, err := rowValuesFunc(db.master.Mapper, v.Type().Elem(), columns)
+		if err != nil {
+			return nil, err
+		}
+		i := 0
+		return func() ([]interface{}, bool, error) {
+			if i >= v.Len() {
+				return nil, false, nil
+			}
+			values, err := toValues(v.Index(i))
+			i++
+			return values, err == nil, err
+		}, nil
+	case reflect.Chan:
+		if v.Type().ChanDir()&reflect.RecvDir == 0 {
+			return nil, fmt.Errorf("sqldb: CopyFrom can't receive rows from send only channel %T", rows)
+		}
+		toValues, err := rowValuesFunc(db.master.Mapper, v.Type().Elem(), columns)
+		if err != nil {
+			return nil, err
+		}
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: v},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		}
+		return func() ([]interface{}, bool, error) {
+			chosen, row, ok := reflect.Select(cases)
+			if chosen == 1 {
+				return nil, false, ctx.Err()
+			}
+			if !ok {
+				return nil, false, nil
+			}
+			values, err := toValues(row)
+			return values, err == nil, err
+		}, nil
+	default:
+		return nil, fmt.Errorf("sqldb: CopyFrom doesn't support rows of type %T", rows)
+	}
+}
+
+// rowValuesFunc returns the function converting a row of type t to the values of the columns.
+// t is either []interface{}, a struct or a pointer to struct mapped by db tag
+func rowValuesFunc(mapper *reflectx.Mapper, t reflect.Type, columns []string) (func(row reflect.Value) ([]interface{}, error), error) {
+	if t == reflect.TypeOf([]interface{}(nil)) {
+		return func(row reflect.Value) ([]interface{}, error) {
+			values := row.Interface().([]interface{})
+			if len(values) != len(columns) {
+				return nil, fmt.Errorf("sqldb: row has %d values for %d columns", len(values), len(columns))
+			}
+			return values, nil
+		}, nil
+	}
+
+	structType := reflectx.Deref(t)
+	if structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("sqldb: rows of %s are not supported, must be struct or []interface{}", t)
+	}
+
+	traversals := mapper.TraversalsByName(structType, columns)
+	for i, traversal := range traversals {
+		if len(traversal) == 0 {
+			return nil, fmt.Errorf("sqldb: column %s is not found in %s", columns[i], structType)
+		}
+	}
+
+	return func(row reflect.Value) ([]interface{}, error) {
+		row = reflect.Indirect(row)
+		if !row.IsValid() {
+			return nil, errors.New("sqldb: row must not be nil")
+		}
+		values := make([]interface{}, len(columns))
+		for i, traversal := range traversals {
+			values[i] = reflectx.FieldByIndexesReadOnly(row, traversal).Interface()
+		}
+		return values, nil
+	}, nil
+}
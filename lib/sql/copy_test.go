@@ -0,0 +1,17 @@
+package sql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kecci/go-toolkit/lib/sql/sqltest"
+)
+
+func TestCopyFromSendOnlyChannel(t *testing.T) {
+	db, _ := sqltest.NewDB(t, "postgres")
+
+	var rows chan<- []interface{} = make(chan []interface{})
+	if _, err := db.CopyFrom(context.Background(), "users", []string{"id"}, rows); err == nil {
+		t.Error("expected error for send only channel")
+	}
+}
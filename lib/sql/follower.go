@@ -0,0 +1,101 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// followerRouter implements Follower by routing every read operation
+// to the follower picked by the DB balancer, or to the transaction carried by the context
+type followerRouter struct {
+	db *DB
+}
+
+// Get from follower database
+func (f *followerRouter) Get(dest interface{}, query string, args ...interface{}) error {
+	return f.GetContext(context.Background(), dest, query, args...)
+}
+
+// Select from follower database
+func (f *followerRouter) Select(dest interface{}, query string, args ...interface{}) error {
+	return f.SelectContext(context.Background(), dest, query, args...)
+}
+
+// Query from follower database
+func (f *followerRouter) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return f.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRow executes QueryRow against follower DB
+func (f *followerRouter) QueryRow(query string, args ...interface{}) *sql.Row {
+	return f.QueryRowContext(context.Background(), query, args...)
+}
+
+// NamedQuery do named query on follower DB
+func (f *followerRouter) NamedQuery(query string, arg interface{}) (*sqlx.Rows, error) {
+	return f.NamedQueryContext(context.Background(), query, arg)
+}
+
+// GetContext from sql database
+func (f *followerRouter) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := withTimeout(ctx, f.db.readTimeout)
+	defer cancel()
+
+	ctx, c, done := f.db.beginRead(ctx, OpGet, query, args)
+	err := c.GetContext(ctx, dest, query, args...)
+	done(err)
+	return err
+}
+
+// SelectContext from sql database
+func (f *followerRouter) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := withTimeout(ctx, f.db.readTimeout)
+	defer cancel()
+
+	ctx, c, done := f.db.beginRead(ctx, OpSelect, query, args)
+	err := c.SelectContext(ctx, dest, query, args...)
+	done(err)
+	return err
+}
+
+// QueryContext from sql database
+func (f *followerRouter) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, c, done := f.db.beginRead(ctx, OpQuery, query, args)
+	rows, err := c.QueryContext(ctx, query, args...)
+	done(err)
+	return rows, err
+}
+
+// QueryRowContext from sql database
+func (f *followerRouter) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, c, done := f.db.beginRead(ctx, OpQueryRow, query, args)
+	row := c.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+	return row
+}
+
+// QueryxContext queries the follower database and returns an *sqlx.Rows
+func (f *followerRouter) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	ctx, c, done := f.db.beginRead(ctx, OpQuery, query, args)
+	rows, err := c.QueryxContext(ctx, query, args...)
+	done(err)
+	return rows, err
+}
+
+// QueryRowxContext queries the follower database and returns an *sqlx.Row
+func (f *followerRouter) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	ctx, c, done := f.db.beginRead(ctx, OpQueryRow, query, args)
+	row := c.QueryRowxContext(ctx, query, args...)
+	done(row.Err())
+	return row
+}
+
+// NamedQueryContext do named query on follower DB
+func (f *followerRouter) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
+	ctx, c, done := f.db.beginRead(ctx, OpNamedQuery, query, []interface{}{arg})
+	rows, err := sqlx.NamedQueryContext(ctx, c, query, arg)
+	done(err)
+	return rows, err
+}
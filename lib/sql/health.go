@@ -0,0 +1,87 @@
+package sql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kecci/go-toolkit/lib/log"
+)
+
+// default value of health check configuration
+const (
+	defaultHealthCheckFailureThreshold = 3
+	defaultHealthCheckSuccessThreshold = 2
+)
+
+// MonitorHealth pings master and every follower in the background, every interval.
+//
+// A node is marked down after failureThreshold consecutive failed pings, and marked up again
+// after successThreshold consecutive successful pings. Follower that is down doesn't serve reads,
+// and reads go to master when no follower is up. Every state change is logged.
+//
+// Default failureThreshold is 3 and default successThreshold is 2
+func (db *DB) MonitorHealth(interval time.Duration, failureThreshold, successThreshold int) {
+	if interval <= 0 {
+		return
+	}
+	if failureThreshold <= 0 {
+		failureThreshold = defaultHealthCheckFailureThreshold
+	}
+	if successThreshold <= 0 {
+		successThreshold = defaultHealthCheckSuccessThreshold
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				db.checkHealth(failureThreshold, successThreshold)
+			case <-db.done:
+				return
+			}
+		}
+	}()
+}
+
+// checkHealth pings every node once and refreshes the followers allowed to serve reads
+func (db *DB) checkHealth(failureThreshold, successThreshold int) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		changed bool
+	)
+
+	for _, n := range db.nodes() {
+		wg.Add(1)
+		go func(n *node) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), db.defaultTimeout)
+			err := n.PingContext(ctx)
+			cancel()
+
+			if !n.recordHealthCheck(err == nil, failureThreshold, successThreshold) {
+				return
+			}
+
+			mu.Lock()
+			changed = true
+			mu.Unlock()
+
+			if err != nil {
+				log.Errorf("sqldb: %s is down, last ping failed with error %s", n.name(), err.Error())
+			} else {
+				log.Infof("sqldb: %s is up", n.name())
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	if changed {
+		db.refreshFollowers()
+	}
+}
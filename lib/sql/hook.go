@@ -0,0 +1,102 @@
+package sql
+
+import (
+	"context"
+	"time"
+)
+
+// list of QueryInfo operation
+const (
+	OpExec          = "exec"
+	OpNamedExec     = "named_exec"
+	OpQuery         = "query"
+	OpQueryRow      = "query_row"
+	OpNamedQuery    = "named_query"
+	OpGet           = "get"
+	OpSelect        = "select"
+	OpBegin         = "begin"
+	OpPrepare       = "prepare"
+	OpCopy          = "copy"
+	OpExecReturning = "exec_returning"
+)
+
+// QueryInfo describes the database operation given to Hook
+type QueryInfo struct {
+	// Operation is the kind of operation, like exec or query. See the Op constants
+	Operation string
+
+	// Query is the sql query, empty for begin
+	Query string
+
+	// Args is the query arguments. Named operation only has the single named argument
+	Args []interface{}
+
+	// Prepared reports whether the operation is executed by a prepared statement
+	Prepared bool
+
+	// Role of the node executing the operation, master or follower
+	Role string
+
+	// DSN of the node without password. Empty if the DB is created from existing *sql.DB
+	DSN string
+
+	// Duration of the operation, only set in Hook.After
+	Duration time.Duration
+}
+
+// Hook intercepts every Master, Follower and prepared statement operation of DB.
+// It can be used for logging, metrics, tracing or auditing
+type Hook interface {
+	// Before is called before the operation is executed.
+	// The returned context is used to execute the operation and passed to After
+	Before(ctx context.Context, info QueryInfo) context.Context
+
+	// After is called after the operation is executed, with the error of the operation if any
+	After(ctx context.Context, info QueryInfo, err error)
+}
+
+// startQuery is called right before an operation is executed, running Before of every hook in order.
+// The returned context must be used to execute the operation,
+// and the returned function must be called with the operation error once it finished
+func (db *DB) startQuery(ctx context.Context, info QueryInfo) (context.Context, func(err error)) {
+	hooks := db.chain
+	if len(hooks) == 0 {
+		return ctx, func(error) {}
+	}
+
+	for _, h := range hooks {
+		ctx = h.Before(ctx, info)
+	}
+
+	start := time.Now()
+	return ctx, func(err error) {
+		info.Duration = time.Since(start)
+		for i := len(hooks) - 1; i >= 0; i-- {
+			hooks[i].After(ctx, info, err)
+		}
+	}
+}
+
+// rebuildHooks rebuilds the hook chain of DB. It must be called every time one of the hooks changes.
+// Tracing runs first, so the other hooks see the span of the operation in the context
+func (db *DB) rebuildHooks() {
+	var chain []Hook
+	if _, noop := db.tracer.(NoopTracer); !noop {
+		chain = append(chain, &tracingHook{db: db})
+	}
+	if db.queryLog != nil {
+		chain = append(chain, db.queryLog)
+	}
+	db.chain = append(chain, db.hooks...)
+}
+
+// queryInfo creates QueryInfo of an operation executed on the node
+func (n *node) queryInfo(op, query string, args []interface{}) QueryInfo {
+	return QueryInfo{
+		Operation: op,
+		Query:     query,
+		Args:      args,
+		Role:      n.role,
+		DSN:       n.dsn,
+	}
+}
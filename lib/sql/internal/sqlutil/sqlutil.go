@@ -0,0 +1,45 @@
+// Package sqlutil contains the query helpers shared by lib/sql and its subpackages
+package sqlutil
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// Placeholders returns n ? placeholders separated by comma, like "?, ?, ?"
+func Placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// IsSQLServer reports whether driver is SQL Server, which differs from the other drivers in savepoint and limit syntax
+func IsSQLServer(driver string) bool {
+	return driver == "sqlserver" || driver == "mssql"
+}
+
+// StructColumns returns the db tagged columns of struct type t, including the fields of embedded structs.
+// t may be a pointer to struct. It returns nil if t is not a struct
+func StructColumns(mapper *reflectx.Mapper, t reflect.Type) []string {
+	t = reflectx.Deref(t)
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var columns []string
+	var walk func(fields []*reflectx.FieldInfo)
+	walk = func(fields []*reflectx.FieldInfo) {
+		for _, f := range fields {
+			if f == nil {
+				continue
+			}
+			if f.Embedded {
+				walk(f.Children)
+				continue
+			}
+			columns = append(columns, f.Path)
+		}
+	}
+	walk(mapper.TypeMap(t).Tree.Children)
+	return columns
+}
@@ -0,0 +1,153 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/kecci/go-toolkit/lib/log"
+)
+
+const defaultReplicaLagCheckInterval = 5 * time.Second
+
+// replicaLagNotRunning is reported as replication lag when the replication of a follower is not running,
+// so it is always considered as lagging
+const replicaLagNotRunning = time.Duration(math.MaxInt64)
+
+// postgresReplicaLagQuery returns replication lag in seconds.
+// follower that already replayed everything it received is not lagging, even if master has been idle for a while
+const postgresReplicaLagQuery = `SELECT CASE
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+// mysqlReplicaLagQuery requires MySQL 8.0.22 or MariaDB 10.5.1
+const mysqlReplicaLagQuery = `SHOW REPLICA STATUS`
+
+// MonitorReplicaLag checks the replication lag of every follower in the background, every interval.
+// Follower lagging behind more than maxLag is taken out of read rotation until it catches up.
+// When no follower qualifies, reads go to master.
+//
+// Only supported for postgres and mysql. Default interval is 5s
+func (db *DB) MonitorReplicaLag(maxLag, interval time.Duration) {
+	if db.driver != "postgres" && db.driver != "mysql" {
+		log.Warnf("sqldb: replication lag check is not supported for driver %s", db.driver)
+		return
+	}
+	if interval <= 0 {
+		interval = defaultReplicaLagCheckInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			db.checkReplicaLag(maxLag)
+
+			select {
+			case <-ticker.C:
+			case <-db.done:
+				return
+			}
+		}
+	}()
+}
+
+// checkReplicaLag checks every follower once and refreshes the followers allowed to serve reads
+func (db *DB) checkReplicaLag(maxLag time.Duration) {
+	var changed bool
+
+	for _, f := range db.followers {
+		// follower can be the master itself when no follower is configured
+		if f == db.master {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), db.defaultTimeout)
+		lag, err := db.replicaLag(ctx, f)
+		cancel()
+		if err != nil {
+			log.Warnf("sqldb: failed to check replication lag of %s with error %s", f.name(), err.Error())
+			continue
+		}
+
+		lagging := lag > maxLag
+		if !f.setLagging(lagging) {
+			continue
+		}
+		changed = true
+
+		if lagging {
+			log.Warnf("sqldb: %s is lagging behind %s, taken out of read rotation", f.name(), formatReplicaLag(lag))
+		} else {
+			log.Infof("sqldb: %s caught up with replication lag %s, back in read rotation", f.name(), formatReplicaLag(lag))
+		}
+	}
+
+	if changed {
+		db.refreshFollowers()
+	}
+}
+
+// replicaLag returns the replication lag of the follower
+func (db *DB) replicaLag(ctx context.Context, f *node) (time.Duration, error) {
+	switch db.driver {
+	case "postgres":
+		var seconds float64
+		if err := f.QueryRowContext(ctx, postgresReplicaLagQuery).Scan(&seconds); err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	case "mysql":
+		return mysqlReplicaLag(ctx, f)
+	default:
+		return 0, fmt.Errorf("replication lag check is not supported for driver %s", db.driver)
+	}
+}
+
+func mysqlReplicaLag(ctx context.Context, f *node) (time.Duration, error) {
+	rows, err := f.QueryxContext(ctx, mysqlReplicaLagQuery)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	// no replication status means it is not a replica, so it never lags
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+
+	status := make(map[string]interface{})
+	if err := rows.MapScan(status); err != nil {
+		return 0, err
+	}
+
+	// MariaDB still names it Seconds_Behind_Master
+	value, ok := status["Seconds_Behind_Source"]
+	if !ok {
+		value, ok = status["Seconds_Behind_Master"]
+	}
+	if !ok {
+		return 0, errors.New("replication status has no Seconds_Behind_Source column")
+	}
+
+	var seconds sql.NullInt64
+	if err := seconds.Scan(value); err != nil {
+		return 0, err
+	}
+	if !seconds.Valid {
+		return replicaLagNotRunning, nil
+	}
+	return time.Duration(seconds.Int64) * time.Second, nil
+}
+
+func formatReplicaLag(lag time.Duration) string {
+	if lag == replicaLagNotRunning {
+		return "unknown (replication is not running)"
+	}
+	return lag.String()
+}
@@ -0,0 +1,79 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	"github.com/kecci/go-toolkit/lib/log"
+)
+
+// Ready returns a channel that is closed once master is connected.
+// It is already closed unless the DB is connected with LazyConnect
+func (db *DB) Ready() <-chan struct{} {
+	return db.ready
+}
+
+// IsReady reports whether master is connected, useful for readiness probe.
+// It is always true unless the DB is connected with LazyConnect
+func (db *DB) IsReady() bool {
+	select {
+	case <-db.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// connectInBackground keeps pinging master and every follower in the background until they are connected.
+// Followers are marked down meanwhile, so reads go to the connected ones or to master
+func (db *DB) connectInBackground(policy RetryPolicy) {
+	db.ready = make(chan struct{})
+
+	for _, f := range db.followers {
+		if f != db.master {
+			f.setDown(true)
+		}
+	}
+	db.refreshFollowers()
+
+	go db.connectNode(db.master, policy, func() {
+		close(db.ready)
+	})
+
+	for _, f := range db.followers {
+		if f == db.master {
+			continue
+		}
+		f := f
+		go db.connectNode(f, policy, func() {
+			f.setDown(false)
+			db.refreshFollowers()
+		})
+	}
+}
+
+// connectNode pings n until it succeeds, then calls onConnected
+func (db *DB) connectNode(n *node, policy RetryPolicy, onConnected func()) {
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), db.defaultTimeout)
+		err := n.PingContext(ctx)
+		cancel()
+
+		if err == nil {
+			log.Infof("sqldb: connected to %s", n.name())
+			onConnected()
+			return
+		}
+
+		delay := policy.backoff(attempt)
+		log.Warnf("sqldb: failed to connect to %s with error %s, retrying in %s. Attempt: %d", n.name(), err.Error(), delay, attempt)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-db.done:
+			timer.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// masterRouter implements Master by executing every write operation on master DB,
+// or on the transaction carried by the context
+type masterRouter struct {
+	db *DB
+}
+
+// Exec executes query on master database
+func (m *masterRouter) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return m.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext use master database to exec query
+func (m *masterRouter) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := withTimeout(ctx, m.db.writeTimeout)
+	defer cancel()
+
+	ctx, c, done := m.db.beginWrite(ctx, OpExec, query, args)
+	res, err := c.ExecContext(ctx, query, args...)
+	done(err)
+	return res, err
+}
+
+// Begin transaction on master DB
+func (m *masterRouter) Begin() (*sql.Tx, error) {
+	return m.BeginTx(context.Background(), nil)
+}
+
+// BeginTx begins transaction on master DB.
+// It returns ErrTxInContext if ctx carries a transaction, see WithTx to nest it
+func (m *masterRouter) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	if _, ok := TxFromContext(ctx); ok {
+		return nil, ErrTxInContext
+	}
+	ctx, done, ok := m.db.startOp(ctx, m.db.master.queryInfo(OpBegin, "", nil))
+	if !ok {
+		return nil, ErrClosed
+	}
+	tx, err := m.db.master.BeginTx(ctx, opts)
+	done(err)
+	return tx, err
+}
+
+// Rebind a query from the default bindtype (QUESTION) to the target bindtype.
+func (m *masterRouter) Rebind(query string) string {
+	return m.db.master.Rebind(query)
+}
+
+// NamedExec do named exec on master DB
+func (m *masterRouter) NamedExec(query string, arg interface{}) (sql.Result, error) {
+	return m.NamedExecContext(context.Background(), query, arg)
+}
+
+// NamedExecContext do named exec on master DB
+func (m *masterRouter) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	ctx, cancel := withTimeout(ctx, m.db.writeTimeout)
+	defer cancel()
+
+	ctx, c, done := m.db.beginWrite(ctx, OpNamedExec, query, []interface{}{arg})
+	res, err := c.NamedExecContext(ctx, query, arg)
+	done(err)
+	return res, err
+}
+
+// BindNamed do BindNamed on master DB
+func (m *masterRouter) BindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	return m.db.master.BindNamed(query, arg)
+}
+
+// ExecReturning executes a write query returning rows, like INSERT or UPDATE with RETURNING,
+// on master DB or on the transaction carried by ctx. It is timed out by the write timeout like Master.ExecContext.
+//
+// The rows are scanned into dest like Follower.SelectContext if dest is a pointer to slice,
+// otherwise the single row is scanned like Follower.GetContext
+func (db *DB) ExecReturning(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := withTimeout(ctx, db.writeTimeout)
+	defer cancel()
+
+	ctx, c, done := db.beginWrite(ctx, OpExecReturning, query, args)
+	var err error
+	if isSlicePtr(dest) {
+		err = c.SelectContext(ctx, dest, query, args...)
+	} else {
+		err = c.GetContext(ctx, dest, query, args...)
+	}
+	done(err)
+	return err
+}
+
+// isSlicePtr reports whether dest is a pointer to slice, other than []byte which is scanned as a single value
+func isSlicePtr(dest interface{}) bool {
+	t := reflect.TypeOf(dest)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Slice {
+		return false
+	}
+	return t.Elem().Elem().Kind() != reflect.Uint8
+}
+
+// conn is the operations shared by *sqlx.DB and *sqlx.Tx
+type conn interface {
+	sqlx.ExtContext
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+	PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error)
+}
+
+// beginWrite prepares a write operation of ctx, see writer and startOp.
+// The returned conn fails every operation with ErrClosed if DB is closed
+func (db *DB) beginWrite(ctx context.Context, op, query string, args []interface{}) (context.Context, conn, func(err error)) {
+	c, n := db.writer(ctx)
+	ctx, done, ok := db.startOp(ctx, n.queryInfo(op, query, args))
+	if !ok {
+		return ctx, closedConn, done
+	}
+	return ctx, c, done
+}
+
+// beginRead prepares a read operation of ctx, see reader and startOp.
+// The returned conn fails every operation with ErrClosed if DB is closed
+func (db *DB) beginRead(ctx context.Context, op, query string, args []interface{}) (context.Context, conn, func(err error)) {
+	c, n := db.reader(ctx)
+	ctx, done, ok := db.startOp(ctx, n.queryInfo(op, query, args))
+	if !ok {
+		return ctx, closedConn, done
+	}
+	return ctx, c, done
+}
+
+// writer returns where the write operation of ctx is executed, along with the node behind it.
+// It is the transaction carried by ctx if any, otherwise master DB
+func (db *DB) writer(ctx context.Context) (conn, *node) {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.Tx, db.master
+	}
+	return db.master.DB, db.master
+}
+
+// reader returns where the read operation of ctx is executed, along with the node behind it.
+// It is the transaction carried by ctx if any, so the read sees the writes of the transaction.
+// Otherwise it is master DB if ctx is marked by WithPrimary, or one of the followers
+func (db *DB) reader(ctx context.Context) (conn, *node) {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.Tx, db.master
+	}
+	n := db.readNode(ctx)
+	return n.DB, n
+}
@@ -0,0 +1,129 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/kecci/go-toolkit/lib/log"
+)
+
+// dialect is the database specific behaviour of the migration
+type dialect interface {
+	// transactional reports whether DDL can run in a transaction
+	transactional() bool
+
+	// lock takes the advisory lock named name on conn, blocking until it is acquired
+	lock(ctx context.Context, conn *sqlx.Conn, name string) error
+	unlock(ctx context.Context, conn *sqlx.Conn, name string) error
+
+	// tableExists reports whether table exists, table may be qualified by its schema
+	tableExists(ctx context.Context, q sqlx.QueryerContext, table string) (bool, error)
+}
+
+func newDialect(driver string) dialect {
+	switch driver {
+	case "postgres":
+		return postgresDialect{}
+	case "mysql":
+		return mysqlDialect{}
+	default:
+		log.Warnf("migrate: advisory lock is not supported for driver %s, concurrent migrations are not prevented", driver)
+		return genericDialect{}
+	}
+}
+
+// postgresDialect runs every migration in a transaction, and locks with pg_advisory_lock
+type postgresDialect struct{}
+
+func (postgresDialect) transactional() bool {
+	return true
+}
+
+func (postgresDialect) lock(ctx context.Context, conn *sqlx.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey(name))
+	return err
+}
+
+func (postgresDialect) unlock(ctx context.Context, conn *sqlx.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey(name))
+	return err
+}
+
+func (postgresDialect) tableExists(ctx context.Context, q sqlx.QueryerContext, table string) (bool, error) {
+	// to_regclass resolves unqualified table by search_path, like the other queries do
+	var exists bool
+	err := q.QueryRowxContext(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists)
+	return exists, err
+}
+
+// lockKey converts the lock name to the key of pg_advisory_lock
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// mysqlDialect can't run migration in a transaction as DDL commits implicitly, and locks with GET_LOCK
+type mysqlDialect struct{}
+
+func (mysqlDialect) transactional() bool {
+	return false
+}
+
+func (mysqlDialect) lock(ctx context.Context, conn *sqlx.Conn, name string) error {
+	// negative timeout waits forever, the wait is still cancelled by ctx
+	var acquired sql.NullInt64
+	if err := conn.QueryRowxContext(ctx, "SELECT GET_LOCK(?, -1)", name).Scan(&acquired); err != nil {
+		return err
+	}
+	if acquired.Int64 != 1 {
+		return fmt.Errorf("failed to acquire lock %s", name)
+	}
+	return nil
+}
+
+func (mysqlDialect) unlock(ctx context.Context, conn *sqlx.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", name)
+	return err
+}
+
+func (mysqlDialect) tableExists(ctx context.Context, q sqlx.QueryerContext, table string) (bool, error) {
+	query := "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+	args := []interface{}{table}
+	if i := strings.IndexByte(table, '.'); i >= 0 {
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = ? AND table_name = ?"
+		args = []interface{}{table[:i], table[i+1:]}
+	}
+
+	var count int
+	err := q.QueryRowxContext(ctx, query, args...).Scan(&count)
+	return count > 0, err
+}
+
+// genericDialect runs every migration in a transaction without lock
+type genericDialect struct{}
+
+func (genericDialect) transactional() bool {
+	return true
+}
+
+func (genericDialect) lock(context.Context, *sqlx.Conn, string) error {
+	return nil
+}
+
+func (genericDialect) unlock(context.Context, *sqlx.Conn, string) error {
+	return nil
+}
+
+// tableExists has no portable catalog to look at, so it selects nothing from table and treats any error as missing table
+func (genericDialect) tableExists(ctx context.Context, q sqlx.QueryerContext, table string) (bool, error) {
+	rows, err := q.QueryContext(ctx, "SELECT 1 FROM "+table+" WHERE 1 = 0")
+	if err != nil {
+		return false, nil
+	}
+	return true, rows.Close()
+}
@@ -0,0 +1,509 @@
+// Package migrate applies versioned SQL migrations to lib/sql.DB.
+//
+// Migrations are read from pairs of files named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// usually embedded to the binary:
+//
+//	//go:embed migrations
+//	var migrations embed.FS
+//
+//	m, err := migrate.New(db, migrations, migrate.Config{Dir: "migrations"})
+//	if err != nil {
+//		return err
+//	}
+//	err = m.Up(ctx)
+//
+// Applied versions are recorded with the checksum of their up SQL, so a migration edited after it is applied is detected.
+// Every migration runs in a transaction when the database supports transactional DDL, like postgres.
+// MySQL commits DDL implicitly, so a migration that fails halfway is marked dirty and must be fixed by hand, see Migrator.Force.
+// MySQL DSN also needs multiStatements=true to run migration with more than one statement.
+//
+// Migrator takes an advisory lock before changing anything, so concurrent instances don't race
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/kecci/go-toolkit/lib/log"
+	sqldb "github.com/kecci/go-toolkit/lib/sql"
+)
+
+// DefaultTable is the default table to record applied migrations
+const DefaultTable = "schema_migrations"
+
+var (
+	// ErrDirty is returned when a migration failed halfway without transaction, and must be fixed by hand before migrating again
+	ErrDirty = errors.New("migrate: database is dirty")
+
+	// ErrEdited is returned when an applied migration is changed after it is applied
+	ErrEdited = errors.New("migrate: applied migration is edited")
+)
+
+var tableRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Config for Migrator
+type Config struct {
+	// Dir in the file system that contains the migration files, default is the root
+	Dir string `json:"dir" yaml:"dir"`
+
+	// Table to record applied migrations, default is schema_migrations
+	Table string `json:"table" yaml:"table"`
+}
+
+// Migrator applies the migrations to the master of DB
+type Migrator struct {
+	db         *sqldb.DB
+	dialect    dialect
+	table      string
+	migrations []*Migration
+}
+
+// MigrationStatus is the state of a migration in the database
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+
+	// Dirty is true when the migration failed halfway without transaction
+	Dirty bool
+
+	// Edited is true when the migration file is changed after it is applied
+	Edited bool
+
+	// Missing is true when the migration is applied but its file doesn't exist anymore
+	Missing bool
+}
+
+// appliedMigration is a row in the migrations table
+type appliedMigration struct {
+	version   int64
+	name      string
+	checksum  string
+	dirty     bool
+	appliedAt time.Time
+}
+
+// New creates Migrator reading the migration files from fsys
+func New(db *sqldb.DB, fsys fs.FS, cfg Config) (*Migrator, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = "."
+	}
+	if cfg.Table == "" {
+		cfg.Table = DefaultTable
+	}
+	if !tableRegex.MatchString(cfg.Table) {
+		return nil, fmt.Errorf("migrate: invalid table name %q", cfg.Table)
+	}
+
+	migrations, err := readMigrations(fsys, cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+
+	return &Migrator{
+		db:         db,
+		dialect:    newDialect(db.DriverName()),
+		table:      cfg.Table,
+		migrations: migrations,
+	}, nil
+}
+
+// Migrations returns every migration read from the file system, sorted by version
+func (m *Migrator) Migrations() []*Migration {
+	return m.migrations
+}
+
+// Up applies every pending migration
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.run(ctx, func(conn *sqlx.Conn, applied map[int64]appliedMigration) error {
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		for _, mig := range m.pending(applied, -1) {
+			if err := m.apply(ctx, conn, mig); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down reverts the last n applied migrations
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	if n <= 0 {
+		return fmt.Errorf("migrate: number of migrations to revert must be positive, got %d", n)
+	}
+	return m.run(ctx, func(conn *sqlx.Conn, applied map[int64]appliedMigration) error {
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		versions := appliedVersions(applied)
+		if n < len(versions) {
+			versions = versions[:n]
+		}
+		return m.revertAll(ctx, conn, versions)
+	})
+}
+
+// To migrates the database to version, applying the pending migrations up to version
+// and reverting the applied migrations after it. Version 0 reverts every migration
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("migrate: migration %d doesn't exist", version)
+	}
+	return m.run(ctx, func(conn *sqlx.Conn, applied map[int64]appliedMigration) error {
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		var revert []int64
+		for _, v := range appliedVersions(applied) {
+			if v > version {
+				revert = append(revert, v)
+			}
+		}
+		if err := m.revertAll(ctx, conn, revert); err != nil {
+			return err
+		}
+
+		for _, mig := range m.pending(applied, version) {
+			if err := m.apply(ctx, conn, mig); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Force records the database as migrated exactly to version without running any migration,
+// after a dirty migration is fixed by hand. The migrations up to version are recorded as applied with their current checksum,
+// which also accepts the edited ones, and every other record is removed. Version 0 removes every record
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("migrate: migration %d doesn't exist", version)
+	}
+	return m.run(ctx, func(conn *sqlx.Conn, _ map[int64]appliedMigration) error {
+		err := m.inTx(ctx, conn, func(tx *sqlx.Tx) error {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+m.table); err != nil {
+				return err
+			}
+			insert := m.insertQuery()
+			for _, mig := range m.migrations {
+				if mig.Version > version {
+					break
+				}
+				if _, err := tx.ExecContext(ctx, insert, mig.Version, mig.Name, mig.Checksum, false); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("migrate: failed to force version %d: %w", version, err)
+		}
+
+		log.Warnf("migrate: forced database to version %d", version)
+		return nil
+	})
+}
+
+// Status returns the state of every migration, sorted by version.
+// It only reads the migrations table, without the lock, so it doesn't wait for the migration run by another instance
+// and doesn't create the table. Missing table means no migration is applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	master := m.db.GetMaster()
+	exists, err := m.dialect.tableExists(ctx, master, m.table)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: failed to check table %s: %w", m.table, err)
+	}
+	applied := make(map[int64]appliedMigration)
+	if exists {
+		if applied, err = m.applied(ctx, master); err != nil {
+			return nil, fmt.Errorf("migrate: failed to read applied migrations: %w", err)
+		}
+	}
+
+	var statuses []MigrationStatus
+	for _, mig := range m.migrations {
+		status := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if a, ok := applied[mig.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = a.appliedAt
+			status.Dirty = a.dirty
+			status.Edited = a.checksum != mig.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+	for _, a := range applied {
+		if m.find(a.version) == nil {
+			statuses = append(statuses, MigrationStatus{
+				Version:   a.version,
+				Name:      a.name,
+				Applied:   true,
+				AppliedAt: a.appliedAt,
+				Dirty:     a.dirty,
+				Missing:   true,
+			})
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// run takes the advisory lock on a master connection, and calls fn with the applied migrations
+func (m *Migrator) run(ctx context.Context, fn func(conn *sqlx.Conn, applied map[int64]appliedMigration) error) error {
+	conn, err := m.db.GetMaster().Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate: failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	lockName := "migrate_" + m.table
+	if err := m.dialect.lock(ctx, conn, lockName); err != nil {
+		return fmt.Errorf("migrate: failed to take lock: %w", err)
+	}
+	defer func() {
+		if err := m.dialect.unlock(context.Background(), conn, lockName); err != nil {
+			log.Warnf("migrate: failed to release lock with error %s", err.Error())
+		}
+	}()
+
+	if err := m.createTable(ctx, conn); err != nil {
+		return fmt.Errorf("migrate: failed to create table %s: %w", m.table, err)
+	}
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("migrate: failed to read applied migrations: %w", err)
+	}
+	return fn(conn, applied)
+}
+
+// verify returns error if the database is dirty or an applied migration is edited
+func (m *Migrator) verify(applied map[int64]appliedMigration) error {
+	for _, v := range appliedVersions(applied) {
+		a := applied[v]
+		if a.dirty {
+			return fmt.Errorf("%w: migration %d_%s failed halfway", ErrDirty, a.version, a.name)
+		}
+		if mig := m.find(v); mig != nil && mig.Checksum != a.checksum {
+			return fmt.Errorf("%w: migration %d_%s", ErrEdited, mig.Version, mig.Name)
+		}
+	}
+	return nil
+}
+
+// pending returns the migrations not applied yet up to version, -1 means every version
+func (m *Migrator) pending(applied map[int64]appliedMigration, version int64) []*Migration {
+	var pending []*Migration
+	for _, mig := range m.migrations {
+		if version >= 0 && mig.Version > version {
+			break
+		}
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig
+		}
+	}
+	return nil
+}
+
+// revertAll reverts the migrations of versions in the given order
+func (m *Migrator) revertAll(ctx context.Context, conn *sqlx.Conn, versions []int64) error {
+	for _, v := range versions {
+		mig := m.find(v)
+		if mig == nil {
+			return fmt.Errorf("migrate: migration %d is applied but its file doesn't exist", v)
+		}
+		if err := m.revert(ctx, conn, mig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply runs the up SQL of mig and records it as applied
+func (m *Migrator) apply(ctx context.Context, conn *sqlx.Conn, mig *Migration) error {
+	start := time.Now()
+	insert := m.insertQuery()
+
+	var err error
+	if m.dialect.transactional() {
+		err = m.inTx(ctx, conn, func(tx *sqlx.Tx) error {
+			if err := execSQL(ctx, tx, mig.up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, insert, mig.Version, mig.Name, mig.Checksum, false)
+			return err
+		})
+	} else {
+		err = m.withoutTx(ctx, conn, mig, mig.up, func() error {
+			_, err := conn.ExecContext(ctx, insert, mig.Version, mig.Name, mig.Checksum, true)
+			return err
+		}, func() error {
+			_, err := conn.ExecContext(ctx, m.db.Rebind("UPDATE "+m.table+" SET dirty = ? WHERE version = ?"), false, mig.Version)
+			return err
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("migrate: failed to apply migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+
+	log.Infof("migrate: applied migration %d_%s in %s", mig.Version, mig.Name, time.Since(start))
+	return nil
+}
+
+// revert runs the down SQL of mig and removes it from the applied migrations
+func (m *Migrator) revert(ctx context.Context, conn *sqlx.Conn, mig *Migration) error {
+	if !mig.hasDown {
+		return fmt.Errorf("migrate: migration %d_%s has no down migration", mig.Version, mig.Name)
+	}
+	start := time.Now()
+	remove := m.db.Rebind("DELETE FROM " + m.table + " WHERE version = ?")
+
+	var err error
+	if m.dialect.transactional() {
+		err = m.inTx(ctx, conn, func(tx *sqlx.Tx) error {
+			if err := execSQL(ctx, tx, mig.down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, remove, mig.Version)
+			return err
+		})
+	} else {
+		err = m.withoutTx(ctx, conn, mig, mig.down, func() error {
+			_, err := conn.ExecContext(ctx, m.db.Rebind("UPDATE "+m.table+" SET dirty = ? WHERE version = ?"), true, mig.Version)
+			return err
+		}, func() error {
+			_, err := conn.ExecContext(ctx, remove, mig.Version)
+			return err
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("migrate: failed to revert migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+
+	log.Infof("migrate: reverted migration %d_%s in %s", mig.Version, mig.Name, time.Since(start))
+	return nil
+}
+
+func (m *Migrator) inTx(ctx context.Context, conn *sqlx.Conn, fn func(tx *sqlx.Tx) error) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Errorf("migrate: failed to rollback migration with error %s", rbErr.Error())
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// withoutTx runs query marking the migration dirty in the meantime,
+// so a failure halfway is detected by the next run
+func (m *Migrator) withoutTx(ctx context.Context, conn *sqlx.Conn, mig *Migration, query string, markDirty, markDone func() error) error {
+	if err := markDirty(); err != nil {
+		return err
+	}
+	if err := execSQL(ctx, conn, query); err != nil {
+		log.Errorf("migrate: migration %d_%s failed halfway and is marked dirty, fix the database and run force", mig.Version, mig.Name)
+		return err
+	}
+	return markDone()
+}
+
+func (m *Migrator) createTable(ctx context.Context, conn *sqlx.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+m.table+` (
+	version BIGINT NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	checksum VARCHAR(64) NOT NULL,
+	dirty BOOLEAN NOT NULL DEFAULT FALSE,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`)
+	return err
+}
+
+func (m *Migrator) applied(ctx context.Context, q sqlx.QueryerContext) (map[int64]appliedMigration, error) {
+	rows, err := q.QueryxContext(ctx, "SELECT version, name, checksum, dirty, applied_at FROM "+m.table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var (
+			a         appliedMigration
+			appliedAt interface{}
+		)
+		if err := rows.Scan(&a.version, &a.name, &a.checksum, &a.dirty, &appliedAt); err != nil {
+			return nil, err
+		}
+		if a.appliedAt, err = parseTime(appliedAt); err != nil {
+			return nil, err
+		}
+		applied[a.version] = a
+	}
+	return applied, rows.Err()
+}
+
+// appliedVersions returns the applied versions, latest first
+func appliedVersions(applied map[int64]appliedMigration) []int64 {
+	versions := make([]int64, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] > versions[j]
+	})
+	return versions
+}
+
+func execSQL(ctx context.Context, e sqlx.ExecerContext, query string) error {
+	if strings.TrimSpace(query) == "" {
+		return nil
+	}
+	_, err := e.ExecContext(ctx, query)
+	return err
+}
+
+// parseTime parses applied_at, which is returned as text by mysql without parseTime=true
+func parseTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case []byte:
+		return time.Parse("2006-01-02 15:04:05", string(t))
+	case string:
+		return time.Parse("2006-01-02 15:04:05", t)
+	case nil:
+		return time.Time{}, nil
+	default:
+		return time.Time{}, fmt.Errorf("unexpected applied_at type %T", v)
+	}
+}
+
+// insertQuery returns the query to record a migration as applied
+func (m *Migrator) insertQuery() string {
+	return m.db.Rebind("INSERT INTO " + m.table + " (version, name, checksum, dirty) VALUES (?, ?, ?, ?)")
+}
@@ -0,0 +1,70 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/kecci/go-toolkit/lib/sql/sqltest"
+)
+
+func newTestMigrator(t *testing.T) (*Migrator, *sqltest.Mock) {
+	db, mock := sqltest.NewDB(t, "postgres")
+	fsys := fstest.MapFS{
+		"1_create_users.up.sql": {Data: []byte("CREATE TABLE users (id INT);")},
+		"2_add_email.up.sql":    {Data: []byte("ALTER TABLE users ADD email TEXT;")},
+	}
+	m, err := New(db, fsys, Config{})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return m, mock
+}
+
+func TestStatusWithoutTable(t *testing.T) {
+	m, mock := newTestMigrator(t)
+	// only the existence check is expected, without lock nor CREATE TABLE
+	mock.ExpectQuery(sqltest.Exact("SELECT to_regclass($1) IS NOT NULL")).
+		WithArgs(DefaultTable).
+		WillReturnRows(sqltest.NewRows("exists").AddRow(false))
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(statuses) != 2 || statuses[0].Applied || statuses[1].Applied {
+		t.Errorf("expected 2 pending migrations, got %+v", statuses)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	m, mock := newTestMigrator(t)
+	appliedAt := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery(sqltest.Exact("SELECT to_regclass($1) IS NOT NULL")).
+		WithArgs(DefaultTable).
+		WillReturnRows(sqltest.NewRows("exists").AddRow(true))
+	mock.ExpectQuery(sqltest.Exact("SELECT version, name, checksum, dirty, applied_at FROM schema_migrations")).
+		WillReturnRows(sqltest.NewRows("version", "name", "checksum", "dirty", "applied_at").
+			AddRow(1, "create_users", "edited", false, appliedAt).
+			AddRow(3, "removed", "checksum", true, appliedAt))
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := []MigrationStatus{
+		{Version: 1, Name: "create_users", Applied: true, AppliedAt: appliedAt, Edited: true},
+		{Version: 2, Name: "add_email"},
+		{Version: 3, Name: "removed", Applied: true, AppliedAt: appliedAt, Dirty: true, Missing: true},
+	}
+	if len(statuses) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, statuses)
+	}
+	for i := range expected {
+		if statuses[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], statuses[i])
+		}
+	}
+}
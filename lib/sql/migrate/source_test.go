@@ -0,0 +1,109 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/2_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD email TEXT;")},
+		"migrations/1_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INT);")},
+		"migrations/1_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"migrations/README.md":               {Data: []byte("not a migration")},
+		"migrations/nested/3_skip.up.sql":    {Data: []byte("SELECT 1;")},
+	}
+
+	migrations, err := readMigrations(fsys, "migrations")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(migrations))
+	}
+
+	first, second := migrations[0], migrations[1]
+	if first.Version != 1 || first.Name != "create_users" || second.Version != 2 || second.Name != "add_email" {
+		t.Fatalf("expected migrations sorted by version, got %d_%s and %d_%s", first.Version, first.Name, second.Version, second.Name)
+	}
+	if first.up != "CREATE TABLE users (id INT);" || first.down != "DROP TABLE users;" || !first.hasDown {
+		t.Errorf("expected up and down of create_users to be paired, got %+v", first)
+	}
+	if second.hasDown {
+		t.Errorf("expected add_email to have no down migration")
+	}
+}
+
+func TestReadMigrationsChecksum(t *testing.T) {
+	read := func(up, down string) string {
+		migrations, err := readMigrations(fstest.MapFS{
+			"1_a.up.sql":   {Data: []byte(up)},
+			"1_a.down.sql": {Data: []byte(down)},
+		}, ".")
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		return migrations[0].Checksum
+	}
+
+	sum := read("CREATE TABLE a (id INT);", "DROP TABLE a;")
+	if sum != read("CREATE TABLE a (id INT);", "DROP TABLE a;") {
+		t.Error("expected the same checksum for the same up migration")
+	}
+	if sum != read("CREATE TABLE a (id INT);", "DROP TABLE IF EXISTS a;") {
+		t.Error("expected the checksum to ignore the down migration")
+	}
+	if sum == read("CREATE TABLE a (id BIGINT);", "DROP TABLE a;") {
+		t.Error("expected a different checksum for an edited up migration")
+	}
+}
+
+func TestReadMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		fsys  fstest.MapFS
+		error string
+	}{
+		{
+			name: "version used by two names",
+			fsys: fstest.MapFS{
+				"1_a.up.sql": {Data: []byte("SELECT 1;")},
+				"1_b.up.sql": {Data: []byte("SELECT 2;")},
+			},
+			error: "migration version 1 is used by both a and b",
+		},
+		{
+			name: "version written twice",
+			fsys: fstest.MapFS{
+				"01_a.up.sql": {Data: []byte("SELECT 1;")},
+				"1_a.up.sql":  {Data: []byte("SELECT 2;")},
+			},
+			error: "migration version 1 has more than one up migration",
+		},
+		{
+			name: "missing up",
+			fsys: fstest.MapFS{
+				"1_a.down.sql": {Data: []byte("SELECT 1;")},
+			},
+			error: "migration 1_a has no up migration",
+		},
+		{
+			name: "invalid version",
+			fsys: fstest.MapFS{
+				"99999999999999999999_a.up.sql": {Data: []byte("SELECT 1;")},
+			},
+			error: "invalid migration version",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readMigrations(tt.fsys, ".")
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
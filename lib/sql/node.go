@@ -0,0 +1,129 @@
+package sql
+
+import (
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// list of node role
+const (
+	roleMaster   = "master"
+	roleFollower = "follower"
+)
+
+// node is a single DB in the cluster along with its routing state
+type node struct {
+	*sqlx.DB
+
+	// role of the node, master or follower
+	role string
+
+	// dsn without password, safe to be logged
+	dsn string
+
+	mu      sync.Mutex
+	lagging bool
+	down    bool
+
+	// consecutive health check result, used to decide when the node is marked up or down
+	failures  int
+	successes int
+}
+
+func newNode(db *sqlx.DB, role, dsn string) *node {
+	return &node{
+		DB:   db,
+		role: role,
+		dsn:  getNoPassDSN(dsn),
+	}
+}
+
+// name of the node to be used in log
+func (n *node) name() string {
+	if n.dsn == "" {
+		return n.role
+	}
+	return n.role + " " + n.dsn
+}
+
+// available reports whether the node may serve reads
+func (n *node) available() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return !n.lagging && !n.down
+}
+
+// setLagging marks the node as lagging or not, and reports whether the state changed
+func (n *node) setLagging(lagging bool) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	changed := n.lagging != lagging
+	n.lagging = lagging
+	return changed
+}
+
+// setDown marks the node as down or up, and reports whether the state changed
+func (n *node) setDown(down bool) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	changed := n.down != down
+	n.down = down
+	n.failures, n.successes = 0, 0
+	return changed
+}
+
+// recordHealthCheck records a health check result of the node.
+// The node is marked down after failureThreshold consecutive failures,
+// and marked up again after successThreshold consecutive successes.
+// It reports whether the node state changed
+func (n *node) recordHealthCheck(healthy bool, failureThreshold, successThreshold int) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if healthy {
+		n.failures = 0
+		n.successes++
+		if n.down && n.successes >= successThreshold {
+			n.down = false
+			return true
+		}
+		return false
+	}
+
+	n.successes = 0
+	n.failures++
+	if !n.down && n.failures >= failureThreshold {
+		n.down = true
+		return true
+	}
+	return false
+}
+
+// followerSet is the followers that are currently allowed to serve reads
+type followerSet struct {
+	nodes []*node
+	dbs   []*sqlx.DB
+}
+
+// refreshFollowers recomputes the followers allowed to serve reads.
+// It must be called every time a follower state changes
+func (db *DB) refreshFollowers() {
+	db.refreshMu.Lock()
+	defer db.refreshMu.Unlock()
+
+	var set followerSet
+	for _, f := range db.followers {
+		if f.available() {
+			set.nodes = append(set.nodes, f)
+			set.dbs = append(set.dbs, f.DB)
+		}
+	}
+	db.available.Store(set)
+}
+
+// availableFollowers returns the followers that are currently allowed to serve reads
+func (db *DB) availableFollowers() followerSet {
+	set, _ := db.available.Load().(followerSet)
+	return set
+}
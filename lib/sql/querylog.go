@@ -0,0 +1,59 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	"github.com/kecci/go-toolkit/lib/log"
+)
+
+// SetQueryLog configures the query logging of DB.
+// Query taking longer than slowThreshold is logged as warning, zero means slow query is not logged.
+// If logAll is true, every query is logged at debug level.
+//
+// It is not safe for concurrent use, call it before the DB is used
+func (db *DB) SetQueryLog(slowThreshold time.Duration, logAll bool) {
+	db.queryLog = nil
+	if slowThreshold > 0 || logAll {
+		db.queryLog = &queryLogHook{
+			slowThreshold: slowThreshold,
+			logAll:        logAll,
+		}
+	}
+	db.rebuildHooks()
+}
+
+// queryLogHook is the Hook logging slow queries, and every query when logAll is true
+type queryLogHook struct {
+	slowThreshold time.Duration
+	logAll        bool
+}
+
+func (h *queryLogHook) Before(ctx context.Context, info QueryInfo) context.Context {
+	return ctx
+}
+
+func (h *queryLogHook) After(ctx context.Context, info QueryInfo, err error) {
+	slow := h.slowThreshold > 0 && info.Duration >= h.slowThreshold
+	if !slow && !h.logAll {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"operation": info.Operation,
+		"query":     info.Query,
+		"args":      len(info.Args),
+		"duration":  info.Duration.String(),
+		"node":      info.Role,
+		"dsn":       info.DSN,
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+
+	if slow {
+		log.WarnWithFields("sqldb: slow query", fields)
+		return
+	}
+	log.DebugWithFields("sqldb: query", fields)
+}
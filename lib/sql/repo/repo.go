@@ -0,0 +1,261 @@
+// Package repo provides CRUD operations of a table mapped to a struct by its db tags, on top of lib/sql.DB.
+//
+//	type User struct {
+//		ID   int64  `db:"id"`
+//		Name string `db:"name"`
+//	}
+//
+//	users, err := repo.New(db, User{}, repo.Config{Table: "users", AutoIncrement: true})
+//	if err != nil {
+//		return err
+//	}
+//	err = users.Insert(ctx, &User{Name: "john"})
+//
+// Writes go to master and reads go to follower, and every operation joins the transaction carried by ctx, see DB.RunInTx of lib/sql
+package repo
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx/reflectx"
+	sqldb "github.com/kecci/go-toolkit/lib/sql"
+	"github.com/kecci/go-toolkit/lib/sql/internal/sqlutil"
+)
+
+// DefaultPrimaryKey is the default primary key column
+const DefaultPrimaryKey = "id"
+
+// Tabler is implemented by model that knows its table name, used when Config.Table is not set
+type Tabler interface {
+	TableName() string
+}
+
+// Config for Repository
+type Config struct {
+	// Table of the model. Default to TableName of the model if it implements Tabler
+	Table string
+
+	// PrimaryKey column of the table. Default to id
+	PrimaryKey string
+
+	// AutoIncrement means the primary key is generated by the database.
+	// Insert leaves out the primary key, and sets the generated one back to the model
+	AutoIncrement bool
+}
+
+// Repository does CRUD operations of a table, mapped to a struct model by its db tags
+type Repository struct {
+	db     *sqldb.DB
+	mapper *reflectx.Mapper
+
+	typ           reflect.Type
+	table         string
+	primaryKey    string
+	autoIncrement bool
+
+	// columns of every db tagged field of the model, including the primary key
+	columns []string
+}
+
+// New creates Repository of model, a struct or pointer to struct with db tagged fields
+func New(db *sqldb.DB, model interface{}, cfg Config) (*Repository, error) {
+	typ := reflectx.Deref(reflect.TypeOf(model))
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("repo: model must be a struct, got %T", model)
+	}
+
+	if cfg.Table == "" {
+		if t, ok := model.(Tabler); ok {
+			cfg.Table = t.TableName()
+		}
+	}
+	if cfg.Table == "" {
+		return nil, fmt.Errorf("repo: table of %s is required", typ)
+	}
+	if cfg.PrimaryKey == "" {
+		cfg.PrimaryKey = DefaultPrimaryKey
+	}
+
+	mapper := db.GetMaster().Mapper
+	columns := sqlutil.StructColumns(mapper, typ)
+
+	var hasPrimaryKey bool
+	for _, c := range columns {
+		if c == cfg.PrimaryKey {
+			hasPrimaryKey = true
+		}
+	}
+	if !hasPrimaryKey {
+		return nil, fmt.Errorf("repo: primary key %s is not found in %s", cfg.PrimaryKey, typ)
+	}
+
+	return &Repository{
+		db:            db,
+		mapper:        mapper,
+		typ:           typ,
+		table:         cfg.Table,
+		primaryKey:    cfg.PrimaryKey,
+		autoIncrement: cfg.AutoIncrement,
+		columns:       columns,
+	}, nil
+}
+
+// Insert inserts entity, a pointer to the model. With AutoIncrement, the generated primary key is set to entity
+func (r *Repository) Insert(ctx context.Context, entity interface{}) error {
+	v, err := r.value(entity)
+	if err != nil {
+		return err
+	}
+
+	columns := r.columns
+	if r.autoIncrement {
+		columns = r.columnsExceptPrimaryKey()
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", r.table, strings.Join(columns, ", "), sqlutil.Placeholders(len(columns)))
+	args := r.values(v, columns)
+
+	if !r.autoIncrement {
+		_, err := r.db.Master.ExecContext(ctx, r.db.Rebind(query), args...)
+		return err
+	}
+
+	id := r.mapper.FieldByName(v, r.primaryKey)
+	if r.db.DriverName() == "postgres" {
+		// postgres has no last insert id, the generated key is returned by the insert instead
+		query += " RETURNING " + r.primaryKey
+		return r.db.ExecReturning(ctx, id.Addr().Interface(), r.db.Rebind(query), args...)
+	}
+
+	result, err := r.db.Master.ExecContext(ctx, r.db.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	lastID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	return setInt(id, lastID)
+}
+
+// Update updates every column of entity, a pointer to the model, by its primary key.
+// It returns the number of rows affected
+func (r *Repository) Update(ctx context.Context, entity interface{}) (int64, error) {
+	v, err := r.value(entity)
+	if err != nil {
+		return 0, err
+	}
+
+	columns := r.columnsExceptPrimaryKey()
+	sets := make([]string, len(columns))
+	for i, c := range columns {
+		sets[i] = c + " = ?"
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = ?", r.table, strings.Join(sets, ", "), r.primaryKey)
+	args := append(r.values(v, columns), r.mapper.FieldByName(v, r.primaryKey).Interface())
+
+	result, err := r.db.Master.ExecContext(ctx, r.db.Rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Upsert inserts entity, a pointer to the model, or updates every column of the existing row with the same primary key.
+// The primary key must be set, even with AutoIncrement
+func (r *Repository) Upsert(ctx context.Context, entity interface{}) error {
+	if _, err := r.value(entity); err != nil {
+		return err
+	}
+
+	rows := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(entity)), 0, 1)
+	rows = reflect.Append(rows, reflect.ValueOf(entity))
+	_, err := r.db.BatchUpsert(ctx, r.table, rows.Interface(), sqldb.BatchOptions{
+		Columns:         r.columns,
+		ConflictColumns: []string{r.primaryKey},
+	})
+	return err
+}
+
+// Delete deletes the row with primary key id, and returns the number of rows affected
+func (r *Repository) Delete(ctx context.Context, id interface{}) (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", r.table, r.primaryKey)
+	result, err := r.db.Master.ExecContext(ctx, r.db.Rebind(query), id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// FindByID gets the row with primary key id into dest, a pointer to the model.
+// It returns sql.ErrNoRows if the row doesn't exist
+func (r *Repository) FindByID(ctx context.Context, dest interface{}, id interface{}) error {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", strings.Join(r.columns, ", "), r.table, r.primaryKey)
+	return r.db.Follower.GetContext(ctx, dest, r.db.Rebind(query), id)
+}
+
+// FindWhere selects the rows matching where into dest, a pointer to slice of the model.
+// where is the condition after WHERE with ? placeholders, like "name = ? AND deleted_at IS NULL".
+// Empty where selects every row
+func (r *Repository) FindWhere(ctx context.Context, dest interface{}, where string, args ...interface{}) error {
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(r.columns, ", "), r.table) + whereClause(where)
+	return r.db.Follower.SelectContext(ctx, dest, r.db.Rebind(query), args...)
+}
+
+// Count returns the number of rows matching where, see FindWhere
+func (r *Repository) Count(ctx context.Context, where string, args ...interface{}) (int64, error) {
+	var count int64
+	query := "SELECT COUNT(*) FROM " + r.table + whereClause(where)
+	err := r.db.Follower.GetContext(ctx, &count, r.db.Rebind(query), args...)
+	return count, err
+}
+
+// value returns the struct pointed by entity, which must be a pointer to the model
+func (r *Repository) value(entity interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(entity)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Type() != r.typ {
+		return reflect.Value{}, fmt.Errorf("repo: entity must be a non nil *%s, got %T", r.typ, entity)
+	}
+	return v.Elem(), nil
+}
+
+// values returns the field values of v for the columns
+func (r *Repository) values(v reflect.Value, columns []string) []interface{} {
+	values := make([]interface{}, len(columns))
+	for i, c := range columns {
+		values[i] = r.mapper.FieldByName(v, c).Interface()
+	}
+	return values
+}
+
+func (r *Repository) columnsExceptPrimaryKey() []string {
+	columns := make([]string, 0, len(r.columns)-1)
+	for _, c := range r.columns {
+		if c != r.primaryKey {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+func whereClause(where string) string {
+	if strings.TrimSpace(where) == "" {
+		return ""
+	}
+	return " WHERE " + where
+}
+
+// setInt sets the generated primary key to the field
+func setInt(field reflect.Value, id int64) error {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		field.SetInt(id)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(uint64(id))
+	default:
+		return fmt.Errorf("repo: can't set generated primary key to field of %s", field.Type())
+	}
+	return nil
+}
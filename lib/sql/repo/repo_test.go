@@ -0,0 +1,123 @@
+package repo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kecci/go-toolkit/lib/sql/repo"
+	"github.com/kecci/go-toolkit/lib/sql/sqltest"
+)
+
+type user struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+func newRepo(t *testing.T, driver string, cfg repo.Config) (*repo.Repository, *sqltest.Mock) {
+	db, mock := sqltest.NewDB(t, driver)
+	cfg.Table = "users"
+	r, err := repo.New(db, user{}, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return r, mock
+}
+
+func TestInsert(t *testing.T) {
+	r, mock := newRepo(t, "postgres", repo.Config{})
+	mock.ExpectExec(sqltest.Exact("INSERT INTO users (id, name) VALUES ($1, $2)")).WithArgs(3, "john")
+
+	if err := r.Insert(context.Background(), &user{ID: 3, Name: "john"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestInsertReturning(t *testing.T) {
+	r, mock := newRepo(t, "postgres", repo.Config{AutoIncrement: true})
+	mock.ExpectQuery(sqltest.Exact("INSERT INTO users (name) VALUES ($1) RETURNING id")).
+		WithArgs("john").
+		WillReturnRows(sqltest.NewRows("id").AddRow(7))
+
+	u := &user{Name: "john"}
+	if err := r.Insert(context.Background(), u); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if u.ID != 7 {
+		t.Errorf("expected returned id 7, got %d", u.ID)
+	}
+}
+
+func TestInsertLastInsertID(t *testing.T) {
+	r, mock := newRepo(t, "mysql", repo.Config{AutoIncrement: true})
+	mock.ExpectExec(sqltest.Exact("INSERT INTO users (name) VALUES (?)")).
+		WithArgs("john").
+		WillReturnResult(sqltest.NewResult(9, 1))
+
+	u := &user{Name: "john"}
+	if err := r.Insert(context.Background(), u); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if u.ID != 9 {
+		t.Errorf("expected last insert id 9, got %d", u.ID)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	r, mock := newRepo(t, "postgres", repo.Config{})
+	mock.ExpectExec(sqltest.Exact("UPDATE users SET name = $1 WHERE id = $2")).
+		WithArgs("john", 3).
+		WillReturnResult(sqltest.NewResult(0, 1))
+
+	n, err := r.Update(context.Background(), &user{ID: 3, Name: "john"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 row affected, got %d", n)
+	}
+}
+
+func TestFindWhere(t *testing.T) {
+	tests := []struct {
+		name  string
+		where string
+		args  []interface{}
+		query string
+	}{
+		{name: "condition", where: "name = ?", args: []interface{}{"john"}, query: "SELECT id, name FROM users WHERE name = $1"},
+		{name: "every row", query: "SELECT id, name FROM users"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock := newRepo(t, "postgres", repo.Config{})
+			mock.ExpectQuery(sqltest.Exact(tt.query)).
+				WithArgs(tt.args...).
+				WillReturnRows(sqltest.NewRows("id", "name").AddRow(3, "john").AddRow(4, "john"))
+
+			var users []user
+			if err := r.FindWhere(context.Background(), &users, tt.where, tt.args...); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(users) != 2 || users[1].ID != 4 {
+				t.Errorf("expected 2 users, got %+v", users)
+			}
+		})
+	}
+}
+
+func TestCount(t *testing.T) {
+	r, mock := newRepo(t, "mysql", repo.Config{})
+	mock.ExpectQuery(sqltest.Exact("SELECT COUNT(*) FROM users WHERE name = ?")).
+		WithArgs("john").
+		WillReturnRows(sqltest.NewRows("count").AddRow(2))
+
+	n, err := r.Count(context.Background(), "name = ?", "john")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected count 2, got %d", n)
+	}
+}
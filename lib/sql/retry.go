@@ -0,0 +1,85 @@
+package sql
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy defines how long to wait between attempts of a retried operation.
+// The delay starts from InitialDelay and is multiplied by Multiplier on every attempt, up to MaxDelay
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts"`
+
+	// InitialDelay is the delay before the second attempt
+	InitialDelay time.Duration `json:"initial_delay" yaml:"initial_delay"`
+
+	// MaxDelay caps the delay between attempts
+	MaxDelay time.Duration `json:"max_delay" yaml:"max_delay"`
+
+	// Multiplier is the factor the delay grows by on every attempt
+	Multiplier float64 `json:"multiplier" yaml:"multiplier"`
+
+	// Jitter randomizes the delay by up to this fraction of it, between 0 and 1.
+	// it avoids many clients retrying at the same time
+	Jitter float64 `json:"jitter" yaml:"jitter"`
+
+	// Deadline is the total time given to every attempt, including the delay between them.
+	// zero means no deadline
+	Deadline time.Duration `json:"deadline" yaml:"deadline"`
+}
+
+// withDefaults fills the zero fields of the policy from def
+func (p RetryPolicy) withDefaults(def RetryPolicy) RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = def.MaxAttempts
+	}
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = def.InitialDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = def.MaxDelay
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = def.Multiplier
+	}
+	if p.Jitter <= 0 {
+		p.Jitter = def.Jitter
+	}
+	if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	if p.Deadline <= 0 {
+		p.Deadline = def.Deadline
+	}
+	return p
+}
+
+// backoff returns the delay after the given attempt failed, attempt starts from 1
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := float64(p.InitialDelay)
+	for i := 1; i < attempt && delay < float64(p.MaxDelay); i++ {
+		delay *= p.Multiplier
+	}
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay -= delay * p.Jitter * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// sleepContext sleeps for d, or returns ctx error earlier when ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,73 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kecci/go-toolkit/lib/sql/internal/sqlutil"
+)
+
+// Savepoint begins a nested transaction by creating a savepoint in tx.
+//
+// Commit of the nested transaction releases the savepoint, and Rollback rolls back to the savepoint
+// without aborting tx. Queries can be executed with either tx or the nested transaction.
+//
+// OnCommit callbacks of the nested transaction run only when tx is committed,
+// while OnRollback callbacks run as soon as the nested transaction is rolled back, or when tx is rolled back
+func (tx *Tx) Savepoint(ctx context.Context) (*Tx, error) {
+	nested := &Tx{
+		Tx:     tx.Tx,
+		db:     tx.db,
+		driver: tx.driver,
+		parent: tx,
+		depth:  tx.depth + 1,
+	}
+	nested.savepoint = fmt.Sprintf("sp_%d", nested.depth)
+
+	// savepoint statements run on the raw transaction, they are part of the span of the nested transaction
+	if _, err := tx.Tx.ExecContext(ctx, savepointQuery(tx.driver, nested.savepoint)); err != nil {
+		return nil, err
+	}
+	return nested, nil
+}
+
+// releaseSavepoint releases the savepoint of a nested transaction
+func (tx *Tx) releaseSavepoint() error {
+	query := releaseSavepointQuery(tx.driver, tx.savepoint)
+	if query == "" {
+		return nil
+	}
+	_, err := tx.Tx.Exec(query)
+	return err
+}
+
+// rollbackToSavepoint rolls back to the savepoint of a nested transaction
+func (tx *Tx) rollbackToSavepoint() error {
+	_, err := tx.Tx.Exec(rollbackSavepointQuery(tx.driver, tx.savepoint))
+	return err
+}
+
+// savepointQuery returns the query to create a savepoint based on the base driver
+func savepointQuery(driver, name string) string {
+	if sqlutil.IsSQLServer(driver) {
+		return "SAVE TRANSACTION " + name
+	}
+	return "SAVEPOINT " + name
+}
+
+// releaseSavepointQuery returns the query to release a savepoint based on the base driver.
+// It is empty if the driver has no such query
+func releaseSavepointQuery(driver, name string) string {
+	if sqlutil.IsSQLServer(driver) {
+		return ""
+	}
+	return "RELEASE SAVEPOINT " + name
+}
+
+// rollbackSavepointQuery returns the query to roll back to a savepoint based on the base driver
+func rollbackSavepointQuery(driver, name string) string {
+	if sqlutil.IsSQLServer(driver) {
+		return "ROLLBACK TRANSACTION " + name
+	}
+	return "ROLLBACK TO SAVEPOINT " + name
+}
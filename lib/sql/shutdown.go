@@ -0,0 +1,191 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/kecci/go-toolkit/lib/log"
+)
+
+// ErrClosed is returned by every operation of DB after it is closed
+var ErrClosed = errors.New("sqldb: database is closed")
+
+// CloseError reports the pools that failed to close
+type CloseError struct {
+	// Errors is the close error keyed by the node name, master or follower_N as in NodeStats
+	Errors map[string]error
+}
+
+func (e *CloseError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = fmt.Sprintf("%s: %s", name, e.Errors[name].Error())
+	}
+	return "sqldb: failed to close " + strings.Join(msgs, "; ")
+}
+
+// Close stops new operations, stops the background workers and closes master and every follower pool.
+// Each pool is closed once, even if follower is the same DB as master.
+// It waits for in-flight operations like Shutdown, up to the default timeout of DB.
+// Use Shutdown to choose how long to wait.
+//
+// The returned error is *CloseError if any pool failed to close,
+// otherwise the error of Shutdown if the in-flight operations didn't finish in time
+func (db *DB) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), db.defaultTimeout)
+	defer cancel()
+	return db.Shutdown(ctx)
+}
+
+// Shutdown stops new operations, then waits for in-flight operations and transactions started by WithTx or RunInTx
+// to finish, before closing master and every follower pool like Close.
+// If ctx is done before they finish, the pools are closed anyway and ctx error is returned.
+//
+// Rows returned by the query operations and transactions started by Begin or BeginTx are not waited
+func (db *DB) Shutdown(ctx context.Context) error {
+	drained := db.inflight.close()
+
+	var drainErr error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		drainErr = fmt.Errorf("sqldb: shutdown before in-flight operations finished: %w", ctx.Err())
+		log.Warn(drainErr.Error())
+	}
+
+	if err := db.closePools(); err != nil {
+		return err
+	}
+	return drainErr
+}
+
+// closePools stops the background workers and closes every distinct pool, only once
+func (db *DB) closePools() error {
+	var err error
+	db.closeOnce.Do(func() {
+		close(db.done)
+
+		failed := make(map[string]error)
+		for _, n := range db.namedNodes() {
+			if closeErr := n.node.Close(); closeErr != nil {
+				failed[n.name] = closeErr
+			}
+		}
+		if len(failed) > 0 {
+			err = &CloseError{Errors: failed}
+		}
+	})
+	return err
+}
+
+// inflightTracker counts the in-flight operations, so closing DB can wait for them
+type inflightTracker struct {
+	mu      sync.Mutex
+	closing bool
+	count   int
+	drained chan struct{}
+}
+
+// enter registers a new operation. It returns false if DB is closing, so the operation must not start
+func (t *inflightTracker) enter() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closing {
+		return false
+	}
+	t.count++
+	return true
+}
+
+// join registers an operation that is part of an operation already in flight, like a statement of a transaction.
+// It is allowed while DB is closing, so the in-flight operation can finish instead of being aborted.
+// It returns false only if every operation already finished
+func (t *inflightTracker) join() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closing && t.count == 0 {
+		return false
+	}
+	t.count++
+	return true
+}
+
+// exit unregisters an operation registered by enter or join
+func (t *inflightTracker) exit() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.count--
+	if t.closing && t.count == 0 {
+		close(t.drained)
+	}
+}
+
+// close rejects new operations, and returns a channel that is closed once every in-flight operation finished
+func (t *inflightTracker) close() <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closing {
+		t.closing = true
+		t.drained = make(chan struct{})
+		if t.count == 0 {
+			close(t.drained)
+		}
+	}
+	return t.drained
+}
+
+// startOp registers an in-flight operation and runs the hooks before it, see startQuery.
+// Operation in the transaction carried by ctx joins the transaction, which is already in flight,
+// so it still runs while DB is closing.
+// It returns false if DB is closed, so the operation must not be executed
+func (db *DB) startOp(ctx context.Context, info QueryInfo) (context.Context, func(err error), bool) {
+	if !db.enterOp(ctx) {
+		return ctx, func(error) {}, false
+	}
+
+	ctx, done := db.startQuery(ctx, info)
+	return ctx, func(err error) {
+		done(err)
+		db.inflight.exit()
+	}, true
+}
+
+// enterOp registers an in-flight operation of ctx, see startOp
+func (db *DB) enterOp(ctx context.Context) bool {
+	if _, ok := TxFromContext(ctx); ok {
+		return db.inflight.join()
+	}
+	return db.inflight.enter()
+}
+
+// closedConn is the conn used once DB is closed, it fails every operation with ErrClosed
+var closedConn conn = sqlx.NewDb(sql.OpenDB(closedConnector{}), "closed")
+
+type closedConnector struct{}
+
+func (closedConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, ErrClosed
+}
+
+func (closedConnector) Driver() driver.Driver {
+	return closedDriver{}
+}
+
+type closedDriver struct{}
+
+func (closedDriver) Open(string) (driver.Conn, error) {
+	return nil, ErrClosed
+}
@@ -0,0 +1,93 @@
+package sql
+
+import (
+	"sync"
+	"testing"
+)
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestInflightTrackerDrain(t *testing.T) {
+	var tracker inflightTracker
+	if !tracker.enter() || !tracker.enter() {
+		t.Fatal("expected enter to succeed before close")
+	}
+
+	drained := tracker.close()
+	if isClosed(drained) {
+		t.Fatal("expected drained to wait for in-flight operations")
+	}
+	if tracker.enter() {
+		t.Error("expected enter to fail while closing")
+	}
+	if !tracker.join() {
+		t.Error("expected join to succeed while operations are in flight")
+	}
+
+	tracker.exit()
+	tracker.exit()
+	if isClosed(drained) {
+		t.Fatal("expected drained to wait for the joined operation")
+	}
+	tracker.exit()
+	if !isClosed(drained) {
+		t.Fatal("expected drained once every operation exited")
+	}
+
+	if tracker.join() {
+		t.Error("expected join to fail once every operation finished")
+	}
+	if tracker.close() != drained {
+		t.Error("expected close to return the same channel when called again")
+	}
+}
+
+func TestInflightTrackerCloseIdle(t *testing.T) {
+	var tracker inflightTracker
+	if !isClosed(tracker.close()) {
+		t.Fatal("expected drained right away without in-flight operation")
+	}
+	if tracker.enter() || tracker.join() {
+		t.Error("expected enter and join to fail after close")
+	}
+}
+
+func TestInflightTrackerConcurrent(t *testing.T) {
+	var tracker inflightTracker
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for j := 0; j < 100; j++ {
+				if !tracker.enter() {
+					return
+				}
+				if tracker.join() {
+					tracker.exit()
+				}
+				tracker.exit()
+			}
+		}()
+	}
+
+	close(start)
+	drained := tracker.close()
+	wg.Wait()
+	if !isClosed(drained) {
+		t.Fatal("expected drained once every goroutine finished")
+	}
+	if tracker.count != 0 {
+		t.Errorf("expected no operation in flight, got %d", tracker.count)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -19,19 +21,53 @@ type DB struct {
 
 	// Follower defines db operation that will be performed against follower DB
 	Follower
-	master   *sqlx.DB
-	follower *sqlx.DB
+	master    *node
+	followers []*node
+
+	// available holds followerSet, the followers currently allowed to serve reads
+	available atomic.Value
+	refreshMu sync.Mutex
+
+	// balancer picks which of the followers serves the read operation
+	balancer Balancer
 
 	// driver define the base driver used. like postgres or mysql. nrpostgres will be converted as postgres
 	driver string
 
 	defaultTimeout time.Duration
+
+	// timeout of read and write operation, see SetTimeouts
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	// queryLog is the Hook for query logging, see SetQueryLog
+	queryLog *queryLogHook
+
+	// hooks intercept every operation, see Hook
+	hooks []Hook
+
+	// tracer starts span for every operation, see Tracer
+	tracer Tracer
+
+	// chain is the hooks run for every operation, including the ones for tracing and query logging
+	chain []Hook
+
+	// done stops the background workers of DB
+	done chan struct{}
+
+	// ready is closed once master is connected, see Ready
+	ready chan struct{}
+
+	// inflight tracks the running operations, so Shutdown can wait for them
+	inflight  inflightTracker
+	closeOnce sync.Once
 }
 
 type DBConfig struct {
 	Driver                string        `json:"driver" yaml:"driver"`
 	MasterDSN             string        `json:"master" yaml:"master"`
 	FollowerDSN           string        `json:"follower" yaml:"follower"`
+	FollowerDSNs          []string      `json:"followers" yaml:"followers"`
 	MaxOpenConnections    int           `json:"max_open_conns" yaml:"max_open_conns"`
 	MaxIdleConnections    int           `json:"max_idle_conns" yaml:"max_idle_conns"`
 	ConnectionMaxLifetime time.Duration `json:"conn_max_lifetime" yaml:"conn_max_lifetime"`
@@ -40,8 +76,91 @@ type DBConfig struct {
 	// won't be used if `NoPingOnOpen`=true
 	Retry int `json:"retry" yaml:"retry"`
 
+	// RetryPolicy defines the delay between connection attempts during Connect.
+	// Retry is used as the number of attempts if RetryPolicy.MaxAttempts is not set.
+	// Default to fixed 3s delay without deadline
+	RetryPolicy RetryPolicy `json:"retry_policy" yaml:"retry_policy"`
+
 	// no Ping when openning DB connection, useful if we don't care whether the server is up or not
 	NoPingOnOpen bool `json:"no_ping_on_open" yaml:"no_ping_on_open"`
+
+	// LazyConnect makes Connect return immediately, and keep connecting to master and followers in the background
+	// following RetryPolicy, without limit on the attempts. DB.Ready is closed once master is connected,
+	// and each follower only serves reads once it is connected
+	LazyConnect bool `json:"lazy_connect" yaml:"lazy_connect"`
+
+	// LoadBalancer is the strategy to spread reads across followers.
+	// one of round_robin, random, least_in_use or weighted. Default to round_robin
+	LoadBalancer string `json:"load_balancer" yaml:"load_balancer"`
+
+	// FollowerWeights is used by weighted load balancer.
+	// the order follows FollowerDSN (if any) then FollowerDSNs
+	FollowerWeights []int `json:"follower_weights" yaml:"follower_weights"`
+
+	// MaxReplicaLag is the maximum replication lag for a follower to serve reads.
+	// follower lagging behind more than this is taken out of read rotation until it catches up,
+	// and reads go to master when no follower qualifies.
+	// zero means replication lag is not checked. Only supported for postgres and mysql
+	MaxReplicaLag time.Duration `json:"max_replica_lag" yaml:"max_replica_lag"`
+
+	// ReplicaLagCheckInterval is how often replication lag is checked. Default to 5s
+	ReplicaLagCheckInterval time.Duration `json:"replica_lag_check_interval" yaml:"replica_lag_check_interval"`
+
+	// HealthCheckInterval is how often master and followers are pinged in the background.
+	// follower that is down doesn't serve reads. zero means no background health check
+	HealthCheckInterval time.Duration `json:"health_check_interval" yaml:"health_check_interval"`
+
+	// number of consecutive failed pings before a node is marked down. Default to 3
+	HealthCheckFailureThreshold int `json:"health_check_failure_threshold" yaml:"health_check_failure_threshold"`
+
+	// number of consecutive successful pings before a node is marked up again. Default to 2
+	HealthCheckSuccessThreshold int `json:"health_check_success_threshold" yaml:"health_check_success_threshold"`
+
+	// SlowQueryThreshold is the duration of a query to be logged as slow query. zero means slow query is not logged
+	SlowQueryThreshold time.Duration `json:"slow_query_threshold" yaml:"slow_query_threshold"`
+
+	// LogQueries logs every query at debug level
+	LogQueries bool `json:"log_queries" yaml:"log_queries"`
+
+	// Hooks intercept every Master, Follower and prepared statement operation, in order
+	Hooks []Hook `json:"-" yaml:"-"`
+
+	// Tracer starts span for every query, prepared statement, transaction and ping
+	Tracer Tracer `json:"-" yaml:"-"`
+
+	// ReadTimeout is the timeout of Follower operations and read statements, except the ones returning rows,
+	// applied to non-context methods and to any context without deadline. zero means no timeout. See SetTimeouts
+	ReadTimeout time.Duration `json:"read_timeout" yaml:"read_timeout"`
+
+	// WriteTimeout is the timeout of Master operations and write statements,
+	// applied to non-context methods and to any context without deadline. zero means no timeout
+	WriteTimeout time.Duration `json:"write_timeout" yaml:"write_timeout"`
+}
+
+// defaultConnectRetryPolicy is used by Connect for the zero fields of RetryPolicy
+var defaultConnectRetryPolicy = RetryPolicy{
+	MaxAttempts:  1,
+	InitialDelay: 3 * time.Second,
+	MaxDelay:     30 * time.Second,
+	Multiplier:   1,
+}
+
+// connectRetryPolicy returns the retry policy of Connect
+func (cfg DBConfig) connectRetryPolicy() RetryPolicy {
+	policy := cfg.RetryPolicy
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = cfg.Retry
+	}
+	return policy.withDefaults(defaultConnectRetryPolicy)
+}
+
+// followerDSNs returns all configured follower DSN, FollowerDSN first
+func (cfg DBConfig) followerDSNs() []string {
+	var dsns []string
+	if cfg.FollowerDSN != "" {
+		dsns = append(dsns, cfg.FollowerDSN)
+	}
+	return append(dsns, cfg.FollowerDSNs...)
 }
 
 // Master defines operation that will be executed to master DB
@@ -54,7 +173,7 @@ type Master interface {
 	// Begin transaction on master DB
 	Begin() (*sql.Tx, error)
 
-	// BeginTx begins transaction on master DB
+	// BeginTx begins transaction on master DB, it fails with ErrTxInContext if ctx carries a transaction
 	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
 
 	// Rebind a query from the default bindtype (QUESTION) to the target bindtype.
@@ -151,43 +270,110 @@ type ReadStatement interface {
 
 // NewFromDB creates *sqldb.DB from the existing *sql.DB.
 //
-// It can be used if we already have the *sql.DB object, usually during the test
-func NewFromDB(masterDB *sql.DB, followerDB *sql.DB, driverName string) *DB {
-	db := newFromSqlxDB(sqlx.NewDb(masterDB, driverName), sqlx.NewDb(followerDB, driverName))
+// It can be used if we already have the *sql.DB object, usually during the test.
+// hooks intercept every Master, Follower and prepared statement operation, in order
+func NewFromDB(masterDB *sql.DB, followerDB *sql.DB, driverName string, hooks ...Hook) *DB {
+	return NewFromDBs(masterDB, []*sql.DB{followerDB}, driverName, hooks...)
+}
+
+// NewFromDBs creates *sqldb.DB from the existing *sql.DB with multiple followers.
+// Reads are spread across the followers using round robin, use SetBalancer to change it.
+// If followerDBs is empty, masterDB is used as follower
+func NewFromDBs(masterDB *sql.DB, followerDBs []*sql.DB, driverName string, hooks ...Hook) *DB {
+	master := newNode(sqlx.NewDb(masterDB, driverName), roleMaster, "")
+	followers := make([]*node, 0, len(followerDBs))
+	for _, f := range followerDBs {
+		if f == masterDB {
+			followers = append(followers, master)
+			continue
+		}
+		followers = append(followers, newNode(sqlx.NewDb(f, driverName), roleFollower, ""))
+	}
+
+	db := newFromSqlxDB(master, followers)
 	db.insertDriver(driverName)
+	db.hooks = hooks
+	db.rebuildHooks()
 	return db
 }
 
-func newFromSqlxDB(masterDB, followerDB *sqlx.DB) *DB {
-	return &DB{
-		Master:         masterDB,
-		Follower:       followerDB,
-		master:         masterDB,
-		follower:       followerDB,
+func newFromSqlxDB(master *node, followers []*node) *DB {
+	if len(followers) == 0 {
+		followers = []*node{master}
+	}
+
+	db := &DB{
+		master:         master,
+		followers:      followers,
+		balancer:       NewRoundRobinBalancer(),
+		tracer:         NoopTracer{},
 		defaultTimeout: 3 * time.Second,
+		done:           make(chan struct{}),
+		ready:          make(chan struct{}),
 	}
+	// only lazily connected DB is not ready from the start
+	close(db.ready)
+
+	db.Master = &masterRouter{db: db}
+	db.Follower = &followerRouter{db: db}
+	db.refreshFollowers()
+	return db
 }
 
 // Connect to kothak sql database object
 func Connect(ctx context.Context, cfg DBConfig) (*DB, error) {
-	masterdb, err := openOrConnect(ctx, cfg.Driver, cfg.MasterDSN, cfg.Retry, cfg.NoPingOnOpen)
+	retryPolicy := cfg.connectRetryPolicy()
+	noPing := cfg.NoPingOnOpen || cfg.LazyConnect
+
+	// check the config before connecting, so a typo doesn't wait for every connect retry.
+	// without follower DSN, master DB is the only follower
+	followerDSNs := cfg.followerDSNs()
+	followerCount := len(followerDSNs)
+	if followerCount == 0 {
+		followerCount = 1
+	}
+	if err := checkBalancer(cfg.LoadBalancer, followerCount, cfg.FollowerWeights); err != nil {
+		return nil, err
+	}
+
+	masterdb, err := openOrConnect(ctx, cfg.Driver, cfg.MasterDSN, retryPolicy, noPing)
 	if err != nil {
 		return nil, err
 	}
 
-	var followerdb *sqlx.DB
+	// opened is closed if Connect fails, so no pool is leaked
+	opened := []*sqlx.DB{masterdb}
+	closeOpened := func() {
+		for _, d := range opened {
+			d.Close()
+		}
+	}
+
+	// if follower DSN is not configured, we use master DB as follower DB
+	var followers []*node
 
-	if cfg.FollowerDSN != "" {
-		followerdb, err = openOrConnect(ctx, cfg.Driver, cfg.FollowerDSN, cfg.Retry, cfg.NoPingOnOpen)
+	for _, dsn := range followerDSNs {
+		followerdb, err := openOrConnect(ctx, cfg.Driver, dsn, retryPolicy, noPing)
 		if err != nil {
+			closeOpened()
 			return nil, err
 		}
-	} else { // if followerDSN is not configured, we use master DB as follower DB
-		followerdb = masterdb
+		opened = append(opened, followerdb)
+		followers = append(followers, newNode(followerdb, roleFollower, dsn))
 	}
 
-	db := newFromSqlxDB(masterdb, followerdb)
+	db := newFromSqlxDB(newNode(masterdb, roleMaster, cfg.MasterDSN), followers)
 	db.insertDriver(cfg.Driver)
+	db.hooks = cfg.Hooks
+	db.SetQueryLog(cfg.SlowQueryThreshold, cfg.LogQueries)
+	db.SetTracer(cfg.Tracer)
+	db.SetTimeouts(cfg.ReadTimeout, cfg.WriteTimeout)
+
+	db.balancer, err = newBalancer(cfg.LoadBalancer, db.GetFollowers(), cfg.FollowerWeights)
+	if err != nil {
+		closeOpened()
+		return nil, err
+	}
 
 	if cfg.MaxIdleConnections > 0 {
 		db.SetMaxIdleConns(cfg.MaxIdleConnections)
@@ -199,19 +385,46 @@ func Connect(ctx context.Context, cfg DBConfig) (*DB, error) {
 	if cfg.ConnectionMaxLifetime > 0 {
 		db.SetConnMaxLifetime(cfg.ConnectionMaxLifetime)
 	}
+
+	if cfg.LazyConnect {
+		db.connectInBackground(retryPolicy)
+	}
+	if cfg.MaxReplicaLag > 0 {
+		db.MonitorReplicaLag(cfg.MaxReplicaLag, cfg.ReplicaLagCheckInterval)
+	}
+	if cfg.HealthCheckInterval > 0 {
+		db.MonitorHealth(cfg.HealthCheckInterval, cfg.HealthCheckFailureThreshold, cfg.HealthCheckSuccessThreshold)
+	}
 	return db, nil
 }
 
 // PrepareWrite creates a prepared statement for write queries.
-// The statement will be executed on Master DB
+// The statement will be executed on Master DB, or in the transaction carried by ctx
 func (db *DB) PrepareWrite(ctx context.Context, query string) (WriteStatement, error) {
-	return db.master.PreparexContext(ctx, query)
+	ctx, cancel := withTimeout(ctx, db.writeTimeout)
+	defer cancel()
+
+	c, n := db.writer(ctx)
+	s, err := db.prepare(ctx, c, n, query)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
 // PrepareRead creates a prepared statement for read queries.
-// The statement will be executed on Follower DB
+// The statement will be executed on one of the Follower DB, picked by the balancer,
+// on Master DB if ctx is marked by WithPrimary, or in the transaction carried by ctx
 func (db *DB) PrepareRead(ctx context.Context, query string) (ReadStatement, error) {
-	return db.follower.PreparexContext(ctx, query)
+	ctx, cancel := withTimeout(ctx, db.readTimeout)
+	defer cancel()
+
+	c, n := db.reader(ctx)
+	s, err := db.prepare(ctx, c, n, query)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
 // Ping to sql database
@@ -221,19 +434,21 @@ func (db *DB) Ping() error {
 	return db.PingContext(ctx)
 }
 
-// PingContext function
-func (db *DB) PingContext(ctx context.Context) error {
-	errCh := make(chan error, 2)
+// PingContext pings master and all followers DB
+func (db *DB) PingContext(ctx context.Context) (err error) {
+	ctx, span := db.startSpan(ctx, "sql.ping")
+	defer func() { endSpan(span, err) }()
 
-	go func() {
-		errCh <- db.master.PingContext(ctx)
-	}()
+	nodes := db.nodes()
+	errCh := make(chan error, len(nodes))
 
-	go func() {
-		errCh <- db.follower.PingContext(ctx)
-	}()
+	for _, n := range nodes {
+		go func(n *node) {
+			errCh <- n.PingContext(ctx)
+		}(n)
+	}
 
-	for i := 0; i < 2; i++ {
+	for i := 0; i < len(nodes); i++ {
 		err := <-errCh
 		if err != nil {
 			return err
@@ -245,30 +460,90 @@ func (db *DB) PingContext(ctx context.Context) error {
 
 // GetMaster get master DB of sqldb
 func (db *DB) GetMaster() *sqlx.DB {
-	return db.master
+	return db.master.DB
 }
 
-// GetFollower return follower db
+// GetFollower return follower db picked by the balancer.
+// It returns master db when no follower is available to serve reads
 func (db *DB) GetFollower() *sqlx.DB {
-	return db.follower
+	return db.pickFollower().DB
+}
+
+// GetFollowers return all follower db, regardless of their state
+func (db *DB) GetFollowers() []*sqlx.DB {
+	followers := make([]*sqlx.DB, len(db.followers))
+	for i, f := range db.followers {
+		followers[i] = f.DB
+	}
+	return followers
+}
+
+// SetBalancer changes the strategy to spread reads across followers.
+// It is not safe for concurrent use, call it before the DB is used
+func (db *DB) SetBalancer(b Balancer) {
+	db.balancer = b
 }
 
 // SetMaxIdleConns to sql database
 func (db *DB) SetMaxIdleConns(n int) {
-	db.master.SetMaxIdleConns(n)
-	db.follower.SetMaxIdleConns(n)
+	for _, node := range db.nodes() {
+		node.SetMaxIdleConns(n)
+	}
 }
 
 // SetMaxOpenConns to sql database
 func (db *DB) SetMaxOpenConns(n int) {
-	db.master.SetMaxOpenConns(n)
-	db.follower.SetMaxOpenConns(n)
+	for _, node := range db.nodes() {
+		node.SetMaxOpenConns(n)
+	}
 }
 
 // SetConnMaxLifetime to sql database
 func (db *DB) SetConnMaxLifetime(t time.Duration) {
-	db.master.SetConnMaxLifetime(t)
-	db.follower.SetConnMaxLifetime(t)
+	for _, node := range db.nodes() {
+		node.SetConnMaxLifetime(t)
+	}
+}
+
+// pickFollower returns the follower that will serve the next read operation.
+// master is returned when no follower is available
+func (db *DB) pickFollower() *node {
+	followers := db.availableFollowers()
+	switch len(followers.nodes) {
+	case 0:
+		return db.master
+	case 1:
+		return followers.nodes[0]
+	}
+
+	picked := db.balancer.Pick(followers.dbs)
+	for _, f := range followers.nodes {
+		if f.DB == picked {
+			return f
+		}
+	}
+	return followers.nodes[0]
+}
+
+// readNode returns the node that will serve the read operation of ctx.
+// It is master if ctx is marked by WithPrimary, otherwise one of the followers
+func (db *DB) readNode(ctx context.Context) *node {
+	if IsPrimary(ctx) {
+		return db.master
+	}
+	return db.pickFollower()
+}
+
+// nodes returns master and followers DB, each distinct DB only listed once.
+// follower can be the same DB as master when no follower is configured
+func (db *DB) nodes() []*node {
+	nodes := []*node{db.master}
+	for _, f := range db.followers {
+		if f != db.master {
+			nodes = append(nodes, f)
+		}
+	}
+	return nodes
 }
 
 // insertDriver will set db module driver with base driver by check type of database.
@@ -283,6 +558,11 @@ func (db *DB) insertDriver(driver string) {
 	}
 }
 
+// DriverName returns the base driver used, like postgres or mysql. nrpostgres is returned as postgres
+func (db *DB) DriverName() string {
+	return db.driver
+}
+
 // Rebind will do usual Rebind by driverName param in db.
 // Please use this rather than Rebind in GetMaster() or GetFollower() to make sure the rebind is correct, especially if you use newrelic
 func (db *DB) Rebind(query string) string {
@@ -298,37 +578,45 @@ func (db *DB) BindNamed(query string, arg interface{}) (string, []interface{}, e
 // openOrConnect will do one these things based on the value of `noPing` argument
 // - true  : call sqlx.Open which only creating sqlx.DB object
 // - false : call sqlx.Connect which is sqlx.Open + Ping to DB.
-//		     if the Ping failed, we retry it for the configured `retry` argument.
-func openOrConnect(ctx context.Context, driver, dsn string, retry int, noPing bool) (*sqlx.DB, error) {
+//		     if the Ping failed, we retry it following the `policy` argument.
+func openOrConnect(ctx context.Context, driver, dsn string, policy RetryPolicy, noPing bool) (*sqlx.DB, error) {
 	if noPing {
 		return sqlx.Open(driver, dsn)
 	}
-	return connectWithRetry(ctx, driver, dsn, retry)
+	return connectWithRetry(ctx, driver, dsn, policy)
 }
 
-func connectWithRetry(ctx context.Context, driver, dsn string, retry int) (*sqlx.DB, error) {
+func connectWithRetry(ctx context.Context, driver, dsn string, policy RetryPolicy) (*sqlx.DB, error) {
 	var (
 		db        *sqlx.DB
 		err       error
 		noPassDSN = getNoPassDSN(dsn)
 	)
 
-	if retry <= 0 {
-		retry = 1
+	if policy.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Deadline)
+		defer cancel()
 	}
 
-	for x := 0; x < retry; x++ {
+	for attempt := 1; ; attempt++ {
 		db, err = connect(ctx, driver, dsn)
 		if err == nil {
 			return db, nil
 		}
-		log.Warnf("SQLDB: failed to connect to %s with error %s", noPassDSN, err.Error())
+		log.Warnf("SQLDB: failed to connect to %s with error %s. Attempt: %d", noPassDSN, err.Error(), attempt)
+
+		if attempt >= policy.MaxAttempts {
+			break
+		}
+
+		delay := policy.backoff(attempt)
+		log.Warnf("sqldb: retrying to connect to %s in %s. Retry: %d", noPassDSN, delay, attempt)
 
-		if x+1 < retry {
-			// continue with condition
-			log.Warnf("sqldb: retrying to connect to %s. Retry: %d", noPassDSN, x+1)
-			// sleep for 3 secs in every retries
-			time.Sleep(time.Second * 3)
+		// stop retrying when ctx is cancelled or the deadline is exceeded
+		if sleepErr := sleepContext(ctx, delay); sleepErr != nil {
+			log.Warnf("sqldb: stop retrying to connect to %s: %s", noPassDSN, sleepErr.Error())
+			break
 		}
 	}
 
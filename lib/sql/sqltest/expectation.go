@@ -0,0 +1,155 @@
+package sqltest
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+)
+
+// list of expectation kind
+const (
+	kindExec     = "exec"
+	kindQuery    = "query"
+	kindBegin    = "begin"
+	kindCommit   = "commit"
+	kindRollback = "rollback"
+)
+
+// Exact returns the pattern to match query as is, instead of as regular expression
+func Exact(query string) string {
+	return "^" + regexp.QuoteMeta(query) + "$"
+}
+
+// Argument matches a query argument, for argument that can't be compared by value
+type Argument interface {
+	Match(v driver.Value) bool
+}
+
+// AnyArg matches any argument
+func AnyArg() Argument {
+	return anyArg{}
+}
+
+type anyArg struct{}
+
+func (anyArg) Match(driver.Value) bool {
+	return true
+}
+
+func (anyArg) String() string {
+	return "<any>"
+}
+
+// Expectation is an expected call to the fake database
+type Expectation struct {
+	kind    string
+	pattern string
+	re      *regexp.Regexp
+
+	// args is nil when any argument is accepted
+	args []interface{}
+
+	rows   *Rows
+	result driver.Result
+	err    error
+
+	met bool
+}
+
+func newExpectation(kind, pattern string) *Expectation {
+	e := &Expectation{kind: kind, pattern: pattern}
+	if pattern != "" {
+		e.re = regexp.MustCompile(pattern)
+	}
+	return e
+}
+
+// WithArgs expects the query to be called with args. Use Argument for argument that can't be compared by value.
+// Without WithArgs, any argument is accepted
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	if args == nil {
+		args = []interface{}{}
+	}
+	e.args = args
+	return e
+}
+
+// WillReturnRows sets the rows returned by the query
+func (e *Expectation) WillReturnRows(rows *Rows) *Expectation {
+	e.rows = rows
+	return e
+}
+
+// WillReturnResult sets the result returned by exec, see NewResult
+func (e *Expectation) WillReturnResult(result driver.Result) *Expectation {
+	e.result = result
+	return e
+}
+
+// WillReturnError sets the error returned by the call
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+func (e *Expectation) matches(kind, query string, args []interface{}) bool {
+	if e.kind != kind {
+		return false
+	}
+	if e.re != nil && !e.re.MatchString(query) {
+		return false
+	}
+	if e.args == nil {
+		return true
+	}
+	if len(e.args) != len(args) {
+		return false
+	}
+	for i, expected := range e.args {
+		if !argMatches(expected, args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func argMatches(expected interface{}, actual interface{}) bool {
+	if m, ok := expected.(Argument); ok {
+		return m.Match(actual)
+	}
+	// compare the same way database/sql converts the actual argument
+	converted, err := driver.DefaultParameterConverter.ConvertValue(expected)
+	if err != nil {
+		converted = expected
+	}
+	return reflect.DeepEqual(converted, actual)
+}
+
+func (e *Expectation) String() string {
+	if e.pattern == "" {
+		return e.kind
+	}
+	if e.args == nil {
+		return fmt.Sprintf("%s matching %q", e.kind, e.pattern)
+	}
+	return fmt.Sprintf("%s matching %q with args %v", e.kind, e.pattern, e.args)
+}
+
+// NewResult creates the result of exec
+func NewResult(lastInsertID, rowsAffected int64) driver.Result {
+	return result{lastInsertID: lastInsertID, rowsAffected: rowsAffected}
+}
+
+type result struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
@@ -0,0 +1,59 @@
+package sqltest
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+)
+
+// Rows is the rows returned by an expected query
+type Rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+// NewRows creates empty rows with the given columns
+func NewRows(columns ...string) *Rows {
+	return &Rows{columns: columns}
+}
+
+// AddRow adds a row, values must follow the order of the columns
+func (r *Rows) AddRow(values ...interface{}) *Rows {
+	if len(values) != len(r.columns) {
+		panic(fmt.Sprintf("sqltest: got %d values for %d columns", len(values), len(r.columns)))
+	}
+
+	row := make([]driver.Value, len(values))
+	for i, v := range values {
+		row[i] = v
+	}
+	r.values = append(r.values, row)
+	return r
+}
+
+// iterator returns driver.Rows reading the rows from the beginning
+func (r *Rows) iterator() driver.Rows {
+	return &rowsIterator{rows: r}
+}
+
+type rowsIterator struct {
+	rows *Rows
+	pos  int
+}
+
+func (it *rowsIterator) Columns() []string {
+	return it.rows.columns
+}
+
+func (it *rowsIterator) Close() error {
+	return nil
+}
+
+func (it *rowsIterator) Next(dest []driver.Value) error {
+	if it.pos >= len(it.rows.values) {
+		return io.EOF
+	}
+	copy(dest, it.rows.values[it.pos])
+	it.pos++
+	return nil
+}
@@ -0,0 +1,165 @@
+// Package sqltest provides a scriptable fake database to unit test repositories built on lib/sql
+// without a real database.
+//
+// Every query the code under test is expected to run is scripted up front, along with the rows, result
+// or error it returns:
+//
+//	db, mock := sqltest.NewDB(t, "postgres")
+//	mock.ExpectQuery(`SELECT name FROM users WHERE id = \$1`).
+//		WithArgs(1).
+//		WillReturnRows(sqltest.NewRows("name").AddRow("john"))
+//
+//	user, err := NewUserRepository(db).FindByID(ctx, 1)
+//
+// Expectations that are not met are reported at the end of the test.
+//
+// For integration tests against a real database, NewTxDB runs the whole test inside one transaction
+// that is rolled back at the end of the test, so tests never leak data into each other.
+package sqltest
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	sqldb "github.com/kecci/go-toolkit/lib/sql"
+)
+
+// TestingT is the subset of *testing.T used by the mock
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+	Cleanup(func())
+}
+
+// Mock holds the expectations of the fake database
+type Mock struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	anyOrder     bool
+
+	// unexpected records every call that didn't match any expectation
+	unexpected []string
+}
+
+// New creates *sql.DB backed by a new Mock.
+// The expectations are checked at the end of the test, and every unmet one is reported as test error.
+//
+// The *sql.DB can be given to sqldb.NewFromDB, or wrapped with sqlx.NewDb to be used as sqldb.Master and sqldb.Follower
+func New(t TestingT) (*sql.DB, *Mock) {
+	m := &Mock{}
+	db := sql.OpenDB(&connector{mock: m})
+
+	t.Cleanup(func() {
+		t.Helper()
+		if err := m.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s", err.Error())
+		}
+		db.Close()
+	})
+	return db, m
+}
+
+// NewDB creates sqldb.DB with master and follower both backed by a new Mock, see New.
+// driverName decides the placeholder used by Rebind and BindNamed, like postgres or mysql
+func NewDB(t TestingT, driverName string) (*sqldb.DB, *Mock) {
+	db, m := New(t)
+	return sqldb.NewFromDB(db, db, driverName), m
+}
+
+// InAnyOrder makes the expectations to be matched in any order.
+// By default they must be met in the order they are added
+func (m *Mock) InAnyOrder() *Mock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.anyOrder = true
+	return m
+}
+
+// ExpectExec expects a query executed by Exec. pattern is a regular expression matched against the query,
+// use Exact to match the query as is
+func (m *Mock) ExpectExec(pattern string) *Expectation {
+	return m.expect(kindExec, pattern)
+}
+
+// ExpectQuery expects a query executed by Query, Get or Select. pattern is a regular expression matched against the query,
+// use Exact to match the query as is
+func (m *Mock) ExpectQuery(pattern string) *Expectation {
+	return m.expect(kindQuery, pattern)
+}
+
+// ExpectBegin expects a transaction to begin
+func (m *Mock) ExpectBegin() *Expectation {
+	return m.expect(kindBegin, "")
+}
+
+// ExpectCommit expects a transaction to be committed
+func (m *Mock) ExpectCommit() *Expectation {
+	return m.expect(kindCommit, "")
+}
+
+// ExpectRollback expects a transaction to be rolled back
+func (m *Mock) ExpectRollback() *Expectation {
+	return m.expect(kindRollback, "")
+}
+
+func (m *Mock) expect(kind, pattern string) *Expectation {
+	e := newExpectation(kind, pattern)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+// ExpectationsWereMet returns error listing every unmet expectation and every unexpected call
+func (m *Mock) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var problems []string
+	for _, e := range m.expectations {
+		if !e.met {
+			problems = append(problems, "unmet expectation: "+e.String())
+		}
+	}
+	problems = append(problems, m.unexpected...)
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("sqltest: %s", strings.Join(problems, "\n\t"))
+}
+
+// match finds the expectation of a call and marks it as met.
+// It returns error if no expectation matches, which is returned to the caller as the call error
+func (m *Mock) match(kind, query string, args []interface{}) (*Expectation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range m.expectations {
+		if e.met {
+			continue
+		}
+		if e.matches(kind, query, args) {
+			e.met = true
+			return e, nil
+		}
+		if !m.anyOrder {
+			return nil, m.unexpectedCall(kind, query, args, "next expectation is "+e.String())
+		}
+	}
+	return nil, m.unexpectedCall(kind, query, args, "no expectation left to match")
+}
+
+func (m *Mock) unexpectedCall(kind, query string, args []interface{}, reason string) error {
+	call := kind
+	if query != "" {
+		call = fmt.Sprintf("%s %q with args %v", kind, query, args)
+	}
+	msg := fmt.Sprintf("unexpected %s, %s", call, reason)
+	m.unexpected = append(m.unexpected, msg)
+	return fmt.Errorf("sqltest: %s", msg)
+}
@@ -0,0 +1,129 @@
+package sqltest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeT records the errors reported by the mock, so unmet expectations can be asserted
+type fakeT struct {
+	errors   []string
+	cleanups []func()
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) Fatalf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) Cleanup(fn func()) {
+	f.cleanups = append(f.cleanups, fn)
+}
+
+// finish runs the cleanups like the end of a test
+func (f *fakeT) finish() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+func TestMockStrictOrder(t *testing.T) {
+	ft := &fakeT{}
+	db, mock := New(ft)
+	mock.ExpectExec(Exact("INSERT INTO a VALUES (?)")).WithArgs(1)
+	mock.ExpectExec(Exact("INSERT INTO b VALUES (?)")).WithArgs(2)
+
+	if _, err := db.Exec("INSERT INTO b VALUES (?)", 2); err == nil {
+		t.Fatal("expected error executing out of order")
+	}
+	if _, err := db.Exec("INSERT INTO a VALUES (?)", 1); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO b VALUES (?)", 2); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	ft.finish()
+	if len(ft.errors) != 1 || !strings.Contains(ft.errors[0], `unexpected exec "INSERT INTO b VALUES (?)"`) {
+		t.Fatalf("expected the out of order call to be reported, got %v", ft.errors)
+	}
+}
+
+func TestMockAnyOrder(t *testing.T) {
+	ft := &fakeT{}
+	db, mock := New(ft)
+	mock.InAnyOrder()
+	mock.ExpectExec(Exact("INSERT INTO a VALUES (?)")).WithArgs(1)
+	mock.ExpectExec(Exact("INSERT INTO b VALUES (?)")).WithArgs(2)
+
+	if _, err := db.Exec("INSERT INTO b VALUES (?)", 2); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO a VALUES (?)", 1); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	ft.finish()
+	if len(ft.errors) != 0 {
+		t.Fatalf("expected no error, got %v", ft.errors)
+	}
+}
+
+func TestMockArgs(t *testing.T) {
+	ft := &fakeT{}
+	db, mock := New(ft)
+	mock.InAnyOrder()
+	mock.ExpectExec("UPDATE a").WithArgs(AnyArg(), "x")
+
+	if _, err := db.Exec("UPDATE a SET b = ? WHERE c = ?", 1, "y"); err == nil {
+		t.Fatal("expected error for mismatched argument")
+	}
+	if _, err := db.Exec("UPDATE a SET b = ? WHERE c = ?", 1, "x"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	ft.finish()
+	if len(ft.errors) != 1 || !strings.Contains(ft.errors[0], "no expectation left to match") {
+		t.Fatalf("expected the mismatched call to be reported, got %v", ft.errors)
+	}
+}
+
+func TestMockUnmetExpectations(t *testing.T) {
+	ft := &fakeT{}
+	db, mock := New(ft)
+	mock.ExpectBegin()
+	mock.ExpectQuery(Exact("SELECT name FROM a")).WillReturnRows(NewRows("name").AddRow("x"))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var name string
+	if err := tx.QueryRow("SELECT name FROM a").Scan(&name); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if name != "x" {
+		t.Fatalf("expected x, got %s", name)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err == nil || !strings.Contains(err.Error(), "unmet expectation: commit") {
+		t.Fatalf("expected unmet commit, got %v", err)
+	}
+	if strings.Contains(err.Error(), "unmet expectation: query") {
+		t.Fatalf("expected the query to be met, got %v", err)
+	}
+
+	tx.Rollback()
+	ft.finish()
+	if len(ft.errors) != 1 || !strings.Contains(ft.errors[0], "unexpected rollback") {
+		t.Fatalf("expected unmet commit and unexpected rollback to be reported, got %v", ft.errors)
+	}
+}
@@ -0,0 +1,381 @@
+package sqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	sqldb "github.com/kecci/go-toolkit/lib/sql"
+)
+
+// TxDriverName is the name of the driver registered to run a test inside a single transaction, see OpenTx
+const TxDriverName = "sqltest_tx"
+
+// guardSavepoint is the savepoint wrapping every statement of the outer transaction, see txSession.guard
+const guardSavepoint = "sqltest_stmt"
+
+var (
+	registerTxDriver sync.Once
+	txDriverInstance = &txDriver{sessions: make(map[string]*txSession)}
+	txSessionID      int64
+)
+
+// OpenTx opens *sql.DB where every connection shares a single transaction on the real database,
+// opened with driverName and dsn. The transaction is rolled back at the end of the test,
+// so nothing written during the test is ever committed.
+//
+// Transaction begun on the returned DB is run as a savepoint inside the outer transaction.
+// Every statement is also run inside its own savepoint, rolled back if the statement fails,
+// so a failed statement doesn't abort the outer transaction on databases like PostgreSQL
+// and the test can go on after an expected error. It costs two more round trips per statement.
+//
+// Statements ending or starting the outer transaction, like COMMIT, ROLLBACK or BEGIN, fail instead of being run,
+// only savepoint statements like ROLLBACK TO SAVEPOINT are allowed.
+// While a transaction begun on the returned DB is open, statements of other connections wait for it to end,
+// as their savepoints would be mixed with its savepoint. Give them a context with deadline
+// if the test may run them while holding the transaction in the same goroutine
+func OpenTx(t TestingT, driverName, dsn string) *sql.DB {
+	t.Helper()
+	registerTxDriver.Do(func() {
+		sql.Register(TxDriverName, txDriverInstance)
+	})
+
+	base, err := sql.Open(driverName, dsn)
+	if err != nil {
+		t.Fatalf("sqltest: failed to open %s database with error %s", driverName, err.Error())
+	}
+	tx, err := base.BeginTx(context.Background(), nil)
+	if err != nil {
+		base.Close()
+		t.Fatalf("sqltest: failed to begin transaction with error %s", err.Error())
+	}
+
+	id := strconv.FormatInt(atomic.AddInt64(&txSessionID, 1), 10)
+	txDriverInstance.add(id, &txSession{db: base, tx: tx})
+
+	db, err := sql.Open(TxDriverName, id)
+	if err != nil {
+		t.Fatalf("sqltest: failed to open transactional database with error %s", err.Error())
+	}
+
+	t.Cleanup(func() {
+		t.Helper()
+		db.Close()
+		txDriverInstance.remove(id)
+		if err := tx.Rollback(); err != nil {
+			t.Errorf("sqltest: failed to rollback test transaction with error %s", err.Error())
+		}
+		base.Close()
+	})
+	return db
+}
+
+// NewTxDB creates sqldb.DB with master and follower sharing a single transaction that is rolled back
+// at the end of the test, see OpenTx. Both master and follower see what is written during the test
+func NewTxDB(t TestingT, driverName, dsn string) *sqldb.DB {
+	t.Helper()
+	db := OpenTx(t, driverName, dsn)
+	return sqldb.NewFromDB(db, db, driverName)
+}
+
+// txDriver opens connections to the transaction of a test, the dsn is the id of the test session
+type txDriver struct {
+	mu       sync.Mutex
+	sessions map[string]*txSession
+}
+
+func (d *txDriver) Open(id string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("sqltest: transactional database %s is closed", id)
+	}
+	return &txConn{session: s}, nil
+}
+
+func (d *txDriver) add(id string, s *txSession) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sessions[id] = s
+}
+
+func (d *txDriver) remove(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.sessions, id)
+}
+
+// txSession is the outer transaction of a test, shared by every connection of the test
+type txSession struct {
+	db *sql.DB
+
+	// mu serializes the use of tx, as a transaction runs on a single connection
+	mu         sync.Mutex
+	tx         *sql.Tx
+	savepoints int
+
+	// owner is the connection running a transaction begun by BeginTx, guarded by mu.
+	// Statements of other connections wait until released is closed, when the transaction ends
+	owner    *txConn
+	released chan struct{}
+}
+
+// lock locks mu for a statement of c, waiting while another connection runs a transaction
+func (s *txSession) lock(ctx context.Context, c *txConn) error {
+	s.mu.Lock()
+	for s.owner != nil && s.owner != c {
+		released := s.released
+		s.mu.Unlock()
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		s.mu.Lock()
+	}
+	return nil
+}
+
+func (s *txSession) exec(ctx context.Context, c *txConn, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := s.lock(ctx, c); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	var result driver.Result
+	err := s.guard(query, func() error {
+		var err error
+		result, err = s.tx.ExecContext(ctx, query, namedArgs(args)...)
+		return err
+	})
+	return result, err
+}
+
+// query reads every row before returning, so the connection is free for the next query
+// even if the caller doesn't close the rows
+func (s *txSession) query(ctx context.Context, c *txConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := s.lock(ctx, c); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	var result driver.Rows
+	err := s.guard(query, func() error {
+		var err error
+		result, err = s.read(ctx, query, args)
+		return err
+	})
+	return result, err
+}
+
+// guard runs a statement inside its own savepoint, rolled back if the statement fails.
+// Without it, a failed statement aborts the whole outer transaction on databases like PostgreSQL
+// and every following statement of the test fails.
+//
+// Savepoint statements are run as is, as wrapping them would release or roll back the guard savepoint itself.
+// Statements ending or starting the outer transaction are rejected, see checkTxControl
+func (s *txSession) guard(query string, fn func() error) error {
+	savepoint, err := checkTxControl(query)
+	if err != nil {
+		return err
+	}
+	if savepoint {
+		return fn()
+	}
+
+	// the guard statements don't use the context of the statement, so they run even if it is canceled
+	if _, err := s.tx.Exec("SAVEPOINT " + guardSavepoint); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if _, rbErr := s.tx.Exec("ROLLBACK TO SAVEPOINT " + guardSavepoint); rbErr != nil {
+			return fmt.Errorf("sqltest: failed to rollback statement with error %s after %w", rbErr.Error(), err)
+		}
+		return err
+	}
+	_, err = s.tx.Exec("RELEASE SAVEPOINT " + guardSavepoint)
+	return err
+}
+
+// read runs query and buffers every row of the result
+func (s *txSession) read(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := s.tx.QueryContext(ctx, query, namedArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := NewRows(columns...)
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		buffered.AddRow(values...)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return buffered.iterator(), nil
+}
+
+// checkTxControl reports whether query creates, releases or rolls back to a savepoint.
+// It returns error if query starts or ends a transaction, as it would end the outer transaction of the test
+func checkTxControl(query string) (savepoint bool, err error) {
+	fields := strings.Fields(strings.ToUpper(query))
+	if len(fields) == 0 {
+		return false, nil
+	}
+
+	switch fields[0] {
+	case "SAVEPOINT", "RELEASE":
+		return true, nil
+	case "ROLLBACK":
+		// ROLLBACK [WORK | TRANSACTION] TO [SAVEPOINT] name
+		rest := fields[1:]
+		if len(rest) > 0 && (rest[0] == "WORK" || rest[0] == "TRANSACTION") {
+			rest = rest[1:]
+		}
+		if len(rest) > 0 && rest[0] == "TO" {
+			return true, nil
+		}
+	case "COMMIT", "BEGIN", "START", "END", "ABORT":
+	default:
+		return false, nil
+	}
+	return false, fmt.Errorf("sqltest: %s would end the test transaction, use BeginTx of DB instead", fields[0])
+}
+
+// txConn is a connection to the outer transaction of a test
+type txConn struct {
+	session *txSession
+}
+
+func (c *txConn) Prepare(query string) (driver.Stmt, error) {
+	return &txStmt{conn: c, query: query}, nil
+}
+
+func (c *txConn) Close() error {
+	return nil
+}
+
+func (c *txConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx begins a savepoint in the outer transaction, the options are ignored.
+// The connection owns the session until the savepoint is released or rolled back, see txSession.owner
+func (c *txConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	s := c.session
+	if err := s.lock(ctx, c); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	s.savepoints++
+	name := "sqltest_" + strconv.Itoa(s.savepoints)
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return nil, err
+	}
+	s.owner = c
+	s.released = make(chan struct{})
+	return &txSavepoint{conn: c, name: name}, nil
+}
+
+func (c *txConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.session.exec(ctx, c, query, args)
+}
+
+func (c *txConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.session.query(ctx, c, query, args)
+}
+
+// CheckNamedValue accepts every argument as is, so it is converted by the base driver instead
+func (c *txConn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+type txStmt struct {
+	conn  *txConn
+	query string
+}
+
+func (s *txStmt) Close() error {
+	return nil
+}
+
+func (s *txStmt) NumInput() int {
+	return -1
+}
+
+func (s *txStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *txStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *txStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *txStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+// txSavepoint is a transaction begun inside the outer transaction of a test
+type txSavepoint struct {
+	conn *txConn
+	name string
+}
+
+func (t *txSavepoint) Commit() error {
+	return t.end("RELEASE SAVEPOINT " + t.name)
+}
+
+func (t *txSavepoint) Rollback() error {
+	return t.end("ROLLBACK TO SAVEPOINT " + t.name)
+}
+
+// end runs query ending the savepoint, and releases the session for the other connections even if it fails
+func (t *txSavepoint) end(query string) error {
+	s := t.conn.session
+	if err := s.lock(context.Background(), t.conn); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	_, err := s.tx.Exec(query)
+	s.owner = nil
+	close(s.released)
+	return err
+}
+
+func namedArgs(args []driver.NamedValue) []interface{} {
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			converted[i] = sql.Named(arg.Name, arg.Value)
+			continue
+		}
+		converted[i] = arg.Value
+	}
+	return converted
+}
@@ -0,0 +1,192 @@
+package sqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordDriver is the base database of the tests, it records every statement by dsn and fails the ones containing FAIL
+type recordDriver struct {
+	mu         sync.Mutex
+	statements map[string][]string
+}
+
+var (
+	registerRecordDriver sync.Once
+	recordDriverInstance = &recordDriver{statements: make(map[string][]string)}
+	recordDSN            int64
+)
+
+func openRecordTx(t *testing.T) (*sql.DB, func() []string) {
+	registerRecordDriver.Do(func() {
+		sql.Register("sqltest_record", recordDriverInstance)
+	})
+	dsn := strconv.FormatInt(atomic.AddInt64(&recordDSN, 1), 10)
+	db := OpenTx(t, "sqltest_record", dsn)
+	return db, func() []string {
+		recordDriverInstance.mu.Lock()
+		defer recordDriverInstance.mu.Unlock()
+		return append([]string(nil), recordDriverInstance.statements[dsn]...)
+	}
+}
+
+func (d *recordDriver) Open(dsn string) (driver.Conn, error) {
+	return &recordConn{driver: d, dsn: dsn}, nil
+}
+
+type recordConn struct {
+	driver *recordDriver
+	dsn    string
+}
+
+func (c *recordConn) record(query string) error {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.driver.statements[c.dsn] = append(c.driver.statements[c.dsn], query)
+	if strings.Contains(query, "FAIL") {
+		return errors.New("statement failed")
+	}
+	return nil
+}
+
+func (c *recordConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (c *recordConn) Close() error {
+	return nil
+}
+
+func (c *recordConn) Begin() (driver.Tx, error) {
+	return c, c.record("BEGIN")
+}
+
+func (c *recordConn) Commit() error {
+	return c.record("COMMIT")
+}
+
+func (c *recordConn) Rollback() error {
+	return c.record("ROLLBACK")
+}
+
+func (c *recordConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if err := c.record(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func TestOpenTxGuardsStatements(t *testing.T) {
+	db, statements := openRecordTx(t)
+
+	if _, err := db.Exec("INSERT INTO a VALUES (1)"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO a VALUES (FAIL)"); err == nil {
+		t.Fatal("expected the failed statement error")
+	}
+
+	expected := []string{
+		"BEGIN",
+		"SAVEPOINT sqltest_stmt",
+		"INSERT INTO a VALUES (1)",
+		"RELEASE SAVEPOINT sqltest_stmt",
+		"SAVEPOINT sqltest_stmt",
+		"INSERT INTO a VALUES (FAIL)",
+		"ROLLBACK TO SAVEPOINT sqltest_stmt",
+	}
+	if got := statements(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected statements %q, got %q", expected, got)
+	}
+}
+
+func TestOpenTxRejectsTransactionControl(t *testing.T) {
+	db, statements := openRecordTx(t)
+
+	for _, query := range []string{"COMMIT", "rollback", "ROLLBACK WORK", "BEGIN", "START TRANSACTION", "END"} {
+		if _, err := db.Exec(query); err == nil {
+			t.Errorf("expected %s to be rejected", query)
+		}
+	}
+	for _, query := range []string{"SAVEPOINT x", "ROLLBACK TO SAVEPOINT x", "ROLLBACK TRANSACTION TO x", "RELEASE SAVEPOINT x"} {
+		if _, err := db.Exec(query); err != nil {
+			t.Errorf("expected %s to be run, got %v", query, err)
+		}
+	}
+
+	expected := []string{"BEGIN", "SAVEPOINT x", "ROLLBACK TO SAVEPOINT x", "ROLLBACK TRANSACTION TO x", "RELEASE SAVEPOINT x"}
+	if got := statements(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected statements %q, got %q", expected, got)
+	}
+}
+
+func TestOpenTxTransactionOwnsSession(t *testing.T) {
+	db, statements := openRecordTx(t)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	outside := make(chan error, 1)
+	go func() {
+		_, err := db.Exec("INSERT INTO outside VALUES (1)")
+		outside <- err
+	}()
+
+	select {
+	case err := <-outside:
+		t.Fatalf("expected the statement of another connection to wait for the transaction, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if _, err := tx.Exec("INSERT INTO inside VALUES (1)"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := <-outside; err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := []string{
+		"BEGIN",
+		"SAVEPOINT sqltest_1",
+		"SAVEPOINT sqltest_stmt",
+		"INSERT INTO inside VALUES (1)",
+		"RELEASE SAVEPOINT sqltest_stmt",
+		"ROLLBACK TO SAVEPOINT sqltest_1",
+		"SAVEPOINT sqltest_stmt",
+		"INSERT INTO outside VALUES (1)",
+		"RELEASE SAVEPOINT sqltest_stmt",
+	}
+	if got := statements(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected statements %q, got %q", expected, got)
+	}
+}
+
+func TestOpenTxWaitHonorsContext(t *testing.T) {
+	db, _ := openRecordTx(t)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer tx.Rollback()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := db.ExecContext(ctx, "INSERT INTO outside VALUES (1)"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded while the transaction is open, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package sql
+
+import (
+	"database/sql"
+	"expvar"
+	"fmt"
+	"time"
+)
+
+const defaultStatsInterval = 10 * time.Second
+
+// NodeStats is the connection pool statistics of a node in the cluster
+type NodeStats struct {
+	// Name identifies the node, master or follower_N where N is the follower index
+	Name string
+
+	// Role of the node, master or follower
+	Role string
+
+	// DSN of the node without password. Empty if the DB is created from existing *sql.DB
+	DSN string
+
+	sql.DBStats
+}
+
+// Stats returns the connection pool statistics of master and every follower.
+// Follower sharing the pool with master is not listed
+func (db *DB) Stats() []NodeStats {
+	nodes := db.namedNodes()
+	stats := make([]NodeStats, len(nodes))
+	for i, n := range nodes {
+		stats[i] = NodeStats{
+			Name:    n.name,
+			Role:    n.node.role,
+			DSN:     n.node.dsn,
+			DBStats: n.node.Stats(),
+		}
+	}
+	return stats
+}
+
+// namedNode is a node along with its name, master or follower_N where N is the follower index
+type namedNode struct {
+	name string
+	node *node
+}
+
+// namedNodes returns master and every follower not sharing the pool with master
+func (db *DB) namedNodes() []namedNode {
+	nodes := []namedNode{{name: roleMaster, node: db.master}}
+	for i, f := range db.followers {
+		if f == db.master {
+			continue
+		}
+		nodes = append(nodes, namedNode{name: fmt.Sprintf("%s_%d", roleFollower, i), node: f})
+	}
+	return nodes
+}
+
+// StatsSink receives the connection pool statistics collected by DB.CollectStats,
+// so it can be published to any metrics backend
+type StatsSink interface {
+	Publish(stats []NodeStats)
+}
+
+// CollectStats publishes the connection pool statistics to sink in the background, every interval.
+// Default interval is 10s
+func (db *DB) CollectStats(sink StatsSink, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultStatsInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			sink.Publish(db.Stats())
+
+			select {
+			case <-ticker.C:
+			case <-db.done:
+				return
+			}
+		}
+	}()
+}
+
+// ExpvarSink is StatsSink that publishes the statistics as expvar map, keyed by node name
+type ExpvarSink struct {
+	vars *expvar.Map
+}
+
+// NewExpvarSink creates ExpvarSink publishing to expvar with the given name.
+// The existing expvar map is reused if the name is already published
+func NewExpvarSink(name string) *ExpvarSink {
+	if vars, ok := expvar.Get(name).(*expvar.Map); ok {
+		return &ExpvarSink{vars: vars}
+	}
+	return &ExpvarSink{vars: expvar.NewMap(name)}
+}
+
+// Publish the statistics to expvar
+func (s *ExpvarSink) Publish(stats []NodeStats) {
+	for _, st := range stats {
+		node := new(expvar.Map)
+		setExpvarString(node, "role", st.Role)
+		setExpvarString(node, "dsn", st.DSN)
+		setExpvarInt(node, "max_open_connections", int64(st.MaxOpenConnections))
+		setExpvarInt(node, "open_connections", int64(st.OpenConnections))
+		setExpvarInt(node, "in_use", int64(st.InUse))
+		setExpvarInt(node, "idle", int64(st.Idle))
+		setExpvarInt(node, "wait_count", st.WaitCount)
+		setExpvarInt(node, "wait_duration_ms", st.WaitDuration.Milliseconds())
+		setExpvarInt(node, "max_idle_closed", st.MaxIdleClosed)
+		setExpvarInt(node, "max_idle_time_closed", st.MaxIdleTimeClosed)
+		setExpvarInt(node, "max_lifetime_closed", st.MaxLifetimeClosed)
+		s.vars.Set(st.Name, node)
+	}
+}
+
+func setExpvarInt(m *expvar.Map, key string, value int64) {
+	v := new(expvar.Int)
+	v.Set(value)
+	m.Set(key, v)
+}
+
+func setExpvarString(m *expvar.Map, key string, value string) {
+	v := new(expvar.String)
+	v.Set(value)
+	m.Set(key, v)
+}
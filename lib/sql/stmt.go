@@ -0,0 +1,129 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// stmt is a prepared statement on a node, implementing both WriteStatement and ReadStatement.
+// Every execution is instrumented like the Master and Follower operations
+type stmt struct {
+	stmt  *sqlx.Stmt
+	db    *DB
+	node  *node
+	query string
+}
+
+// ExecContext executes a prepared statement with the given arguments and returns a Result summarizing the effect of the statement.
+func (s *stmt) ExecContext(ctx context.Context, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := withTimeout(ctx, s.db.writeTimeout)
+	defer cancel()
+
+	ctx, done, ok := s.db.startOp(ctx, s.queryInfo(OpExec, args))
+	if !ok {
+		return nil, ErrClosed
+	}
+	res, err := s.stmt.ExecContext(ctx, args...)
+	done(err)
+	return res, err
+}
+
+// GetContext using the prepared statement.
+func (s *stmt) GetContext(ctx context.Context, dest interface{}, args ...interface{}) error {
+	ctx, cancel := withTimeout(ctx, s.db.readTimeout)
+	defer cancel()
+
+	ctx, done, ok := s.db.startOp(ctx, s.queryInfo(OpGet, args))
+	if !ok {
+		return ErrClosed
+	}
+	err := s.stmt.GetContext(ctx, dest, args...)
+	done(err)
+	return err
+}
+
+// SelectContext using the prepared statement.
+func (s *stmt) SelectContext(ctx context.Context, dest interface{}, args ...interface{}) error {
+	ctx, cancel := withTimeout(ctx, s.db.readTimeout)
+	defer cancel()
+
+	ctx, done, ok := s.db.startOp(ctx, s.queryInfo(OpSelect, args))
+	if !ok {
+		return ErrClosed
+	}
+	err := s.stmt.SelectContext(ctx, dest, args...)
+	done(err)
+	return err
+}
+
+// QueryContext using the prepared statement.
+func (s *stmt) QueryContext(ctx context.Context, args ...interface{}) (*sql.Rows, error) {
+	ctx, done, ok := s.db.startOp(ctx, s.queryInfo(OpQuery, args))
+	if !ok {
+		return nil, ErrClosed
+	}
+	rows, err := s.stmt.QueryContext(ctx, args...)
+	done(err)
+	return rows, err
+}
+
+// QueryRowContext using the prepared statement.
+func (s *stmt) QueryRowContext(ctx context.Context, args ...interface{}) *sql.Row {
+	ctx, done, ok := s.db.startOp(ctx, s.queryInfo(OpQueryRow, args))
+	if !ok {
+		return closedConn.QueryRowContext(ctx, s.query, args...)
+	}
+	row := s.stmt.QueryRowContext(ctx, args...)
+	done(row.Err())
+	return row
+}
+
+// QueryRowxContext using the prepared statement.
+func (s *stmt) QueryRowxContext(ctx context.Context, args ...interface{}) *sqlx.Row {
+	ctx, done, ok := s.db.startOp(ctx, s.queryInfo(OpQueryRow, args))
+	if !ok {
+		return closedConn.QueryRowxContext(ctx, s.query, args...)
+	}
+	row := s.stmt.QueryRowxContext(ctx, args...)
+	done(row.Err())
+	return row
+}
+
+// QueryxContext using the prepared statement.
+func (s *stmt) QueryxContext(ctx context.Context, args ...interface{}) (*sqlx.Rows, error) {
+	ctx, done, ok := s.db.startOp(ctx, s.queryInfo(OpQuery, args))
+	if !ok {
+		return nil, ErrClosed
+	}
+	rows, err := s.stmt.QueryxContext(ctx, args...)
+	done(err)
+	return rows, err
+}
+
+// queryInfo creates QueryInfo of the statement execution
+func (s *stmt) queryInfo(op string, args []interface{}) QueryInfo {
+	info := s.node.queryInfo(op, s.query, args)
+	info.Prepared = true
+	return info
+}
+
+// Close closes the statement.
+func (s *stmt) Close() error {
+	return s.stmt.Close()
+}
+
+// prepare creates the prepared statement on c, which is backed by node n
+func (db *DB) prepare(ctx context.Context, c conn, n *node, query string) (*stmt, error) {
+	ctx, done, ok := db.startOp(ctx, n.queryInfo(OpPrepare, query, nil))
+	if !ok {
+		return nil, ErrClosed
+	}
+	prepared, err := c.PreparexContext(ctx, query)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{stmt: prepared, db: db, node: n, query: query}, nil
+}
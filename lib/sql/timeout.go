@@ -0,0 +1,31 @@
+package sql
+
+import (
+	"context"
+	"time"
+)
+
+// SetTimeouts sets the timeout of read and write operations of Master, Follower and prepared statements.
+// The timeout applies to the non-context methods, and to any context without deadline.
+// Zero means no timeout.
+//
+// It doesn't apply to Begin and BeginTx, as their context bounds the whole transaction.
+// It doesn't apply to the operations returning rows either, like Query, QueryRow, Queryx, QueryRowx and NamedQuery
+// of Follower, Tx and prepared statements, as the rows are read after the operation returns.
+// Their context is used as is, give it a deadline to bound the query and the reading of its rows.
+// It is not safe for concurrent use, call it before the DB is used
+func (db *DB) SetTimeouts(read, write time.Duration) {
+	db.readTimeout = read
+	db.writeTimeout = write
+}
+
+// withTimeout returns ctx with timeout d if ctx has no deadline yet. Zero d means no timeout
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,202 @@
+package sql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// list of span attribute set by DB
+const (
+	AttrDBSystem    = "db.system"
+	AttrDBStatement = "db.statement"
+	AttrDBOperation = "db.operation"
+	AttrDBRole      = "db.role"
+	AttrDBPrepared  = "db.prepared"
+)
+
+// Tracer starts spans for database operations, so the latency of DB shows up in request traces.
+// It is meant to be implemented on top of the tracing library used by the service
+type Tracer interface {
+	// Start starts a span named name as a child of the span carried by ctx, if any.
+	// The returned context carries the new span
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation
+type Span interface {
+	// SetAttribute sets an attribute of the span
+	SetAttribute(key string, value interface{})
+
+	// SetError marks the span as failed with err
+	SetError(err error)
+
+	// End finishes the span
+	End()
+}
+
+// SetTracer sets the tracer of every query, prepared statement, transaction and ping of DB.
+//
+// It is not safe for concurrent use, call it before the DB is used
+func (db *DB) SetTracer(t Tracer) {
+	if t == nil {
+		t = NoopTracer{}
+	}
+	db.tracer = t
+	db.rebuildHooks()
+}
+
+// startSpan starts a span of an operation of DB, with the base attributes set
+func (db *DB) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	ctx, span := db.tracer.Start(ctx, name)
+	span.SetAttribute(AttrDBSystem, dbSystem(db.driver))
+	return ctx, span
+}
+
+// endSpan finishes span, marking it failed if err is not nil
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.SetError(err)
+	}
+	span.End()
+}
+
+// dbSystem returns the db.system attribute value of the base driver
+func dbSystem(driver string) string {
+	switch driver {
+	case "postgres":
+		return "postgresql"
+	case "sqlserver", "mssql":
+		return "mssql"
+	default:
+		return driver
+	}
+}
+
+type spanContextKey struct{}
+
+// tracingHook is the Hook starting a span for every operation
+type tracingHook struct {
+	db *DB
+}
+
+func (h *tracingHook) Before(ctx context.Context, info QueryInfo) context.Context {
+	ctx, span := h.db.startSpan(ctx, "sql."+info.Operation)
+	span.SetAttribute(AttrDBOperation, info.Operation)
+	span.SetAttribute(AttrDBRole, info.Role)
+	if info.Query != "" {
+		span.SetAttribute(AttrDBStatement, info.Query)
+	}
+	if info.Prepared {
+		span.SetAttribute(AttrDBPrepared, true)
+	}
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+func (h *tracingHook) After(ctx context.Context, info QueryInfo, err error) {
+	if span, ok := ctx.Value(spanContextKey{}).(Span); ok {
+		endSpan(span, err)
+	}
+}
+
+// NoopTracer is Tracer that does nothing. It is the default Tracer of DB
+type NoopTracer struct{}
+
+// Start returns ctx as is and a span that does nothing
+func (NoopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) SetError(err error)                         {}
+func (noopSpan) End()                                       {}
+
+// InMemoryTracer is Tracer that records every span in memory.
+// It is meant to be used in tests to assert the traced operations
+type InMemoryTracer struct {
+	mu    sync.Mutex
+	spans []*InMemorySpan
+}
+
+// NewInMemoryTracer creates InMemoryTracer
+func NewInMemoryTracer() *InMemoryTracer {
+	return &InMemoryTracer{}
+}
+
+// Start records a new span as a child of the InMemorySpan carried by ctx, if any
+func (t *InMemoryTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(inMemorySpanContextKey{}).(*InMemorySpan)
+	span := &InMemorySpan{
+		Name:       name,
+		Parent:     parent,
+		Attributes: make(map[string]interface{}),
+		StartTime:  time.Now(),
+	}
+
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+
+	return context.WithValue(ctx, inMemorySpanContextKey{}, span), span
+}
+
+// Spans returns every recorded span in the order they are started
+func (t *InMemoryTracer) Spans() []*InMemorySpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	spans := make([]*InMemorySpan, len(t.spans))
+	copy(spans, t.spans)
+	return spans
+}
+
+// Reset removes every recorded span
+func (t *InMemoryTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = nil
+}
+
+type inMemorySpanContextKey struct{}
+
+// InMemorySpan is the span recorded by InMemoryTracer.
+// The fields should only be read after the span ended
+type InMemorySpan struct {
+	Name       string
+	Parent     *InMemorySpan
+	Attributes map[string]interface{}
+	Err        error
+	StartTime  time.Time
+	EndTime    time.Time
+
+	mu sync.Mutex
+}
+
+// SetAttribute sets an attribute of the span
+func (s *InMemorySpan) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Attributes[key] = value
+}
+
+// SetError marks the span as failed with err
+func (s *InMemorySpan) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Err = err
+}
+
+// End finishes the span
+func (s *InMemorySpan) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.EndTime = time.Now()
+}
+
+// Ended reports whether the span has ended
+func (s *InMemorySpan) Ended() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.EndTime.IsZero()
+}
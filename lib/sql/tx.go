@@ -0,0 +1,435 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/kecci/go-toolkit/lib/log"
+	"github.com/lib/pq"
+)
+
+// ErrTxInContext is returned by Master.BeginTx when the context already carries a transaction,
+// as the new transaction would run on another connection, outside the carried one.
+// Use WithTx or RunInTx with that context to begin a nested transaction instead
+var ErrTxInContext = errors.New("sqldb: context already carries a transaction, use WithTx or RunInTx to nest it")
+
+// defaultTxRetryPolicy is used by WithRetryTx for the zero fields of the given policy
+var defaultTxRetryPolicy = RetryPolicy{
+	MaxAttempts:  3,
+	InitialDelay: 50 * time.Millisecond,
+	MaxDelay:     time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+}
+
+// Tx is transaction on master DB.
+// It binds queries using the base driver of DB, like DB.Rebind and DB.BindNamed.
+//
+// Queries of the transaction are run through DB like the queries of a context carrying the transaction,
+// so they have the same span, hooks, slow query log and timeouts, see RunInTx.
+// Prepared statements of the transaction, from Preparex and the like, are not instrumented
+type Tx struct {
+	*sqlx.Tx
+
+	// db runs the queries of the transaction
+	db *DB
+
+	// ctx carries the transaction and the span of WithTx or RunInTx, see Context
+	ctx context.Context
+
+	// driver define the base driver used, same with DB
+	driver string
+
+	// parent is the outer transaction of a nested transaction, which is a savepoint in parent
+	parent    *Tx
+	savepoint string
+	depth     int
+
+	hooksMu    sync.Mutex
+	onCommit   []func()
+	onRollback []func()
+}
+
+// Rebind will do usual Rebind by driverName param in db.
+// Please use this rather than Rebind in sqlx.Tx to make sure the rebind is correct, especially if you use newrelic
+func (tx *Tx) Rebind(query string) string {
+	return sqlx.Rebind(sqlx.BindType(tx.driver), query)
+}
+
+// BindNamed will do usual BindNamed by driverName param in db.
+// Please use this rather than BindNamed in sqlx.Tx to make sure the rebind is correct, especially if you use newrelic
+func (tx *Tx) BindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	return sqlx.BindNamed(sqlx.BindType(tx.driver), query, arg)
+}
+
+// Context returns the context carrying the transaction, given to the callback of RunInTx.
+// Pass it to nested WithTx, RunInTx or repository calls inside the callback of WithTx,
+// so they join the transaction instead of running on another connection
+func (tx *Tx) Context() context.Context {
+	if tx.ctx == nil {
+		return ContextWithTx(context.Background(), tx)
+	}
+	return tx.ctx
+}
+
+// Exec executes query in the transaction
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext executes query in the transaction, see Master.ExecContext
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.db.Master.ExecContext(ContextWithTx(ctx, tx), query, args...)
+}
+
+// MustExec executes query in the transaction and panics on error
+func (tx *Tx) MustExec(query string, args ...interface{}) sql.Result {
+	return tx.MustExecContext(context.Background(), query, args...)
+}
+
+// MustExecContext executes query in the transaction and panics on error
+func (tx *Tx) MustExecContext(ctx context.Context, query string, args ...interface{}) sql.Result {
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// NamedExec do named exec in the transaction
+func (tx *Tx) NamedExec(query string, arg interface{}) (sql.Result, error) {
+	return tx.NamedExecContext(context.Background(), query, arg)
+}
+
+// NamedExecContext do named exec in the transaction, see Master.NamedExecContext
+func (tx *Tx) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	return tx.db.Master.NamedExecContext(ContextWithTx(ctx, tx), query, arg)
+}
+
+// Get a single row in the transaction
+func (tx *Tx) Get(dest interface{}, query string, args ...interface{}) error {
+	return tx.GetContext(context.Background(), dest, query, args...)
+}
+
+// GetContext gets a single row in the transaction, see Follower.GetContext
+func (tx *Tx) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return tx.db.Follower.GetContext(ContextWithTx(ctx, tx), dest, query, args...)
+}
+
+// Select rows in the transaction
+func (tx *Tx) Select(dest interface{}, query string, args ...interface{}) error {
+	return tx.SelectContext(context.Background(), dest, query, args...)
+}
+
+// SelectContext selects rows in the transaction, see Follower.SelectContext
+func (tx *Tx) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return tx.db.Follower.SelectContext(ContextWithTx(ctx, tx), dest, query, args...)
+}
+
+// Query in the transaction
+func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext queries in the transaction, see Follower.QueryContext
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.db.Follower.QueryContext(ContextWithTx(ctx, tx), query, args...)
+}
+
+// QueryRow queries a single row in the transaction
+func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.QueryRowContext(context.Background(), query, args...)
+}
+
+// QueryRowContext queries a single row in the transaction, see Follower.QueryRowContext
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return tx.db.Follower.QueryRowContext(ContextWithTx(ctx, tx), query, args...)
+}
+
+// Queryx queries in the transaction and returns an *sqlx.Rows
+func (tx *Tx) Queryx(query string, args ...interface{}) (*sqlx.Rows, error) {
+	return tx.QueryxContext(context.Background(), query, args...)
+}
+
+// QueryxContext queries in the transaction and returns an *sqlx.Rows, see Follower.QueryxContext
+func (tx *Tx) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	return tx.db.Follower.QueryxContext(ContextWithTx(ctx, tx), query, args...)
+}
+
+// QueryRowx queries a single row in the transaction and returns an *sqlx.Row
+func (tx *Tx) QueryRowx(query string, args ...interface{}) *sqlx.Row {
+	return tx.QueryRowxContext(context.Background(), query, args...)
+}
+
+// QueryRowxContext queries a single row in the transaction and returns an *sqlx.Row, see Follower.QueryRowxContext
+func (tx *Tx) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	return tx.db.Follower.QueryRowxContext(ContextWithTx(ctx, tx), query, args...)
+}
+
+// NamedQuery do named query in the transaction
+func (tx *Tx) NamedQuery(query string, arg interface{}) (*sqlx.Rows, error) {
+	return tx.NamedQueryContext(context.Background(), query, arg)
+}
+
+// NamedQueryContext do named query in the transaction, see Follower.NamedQueryContext
+func (tx *Tx) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
+	return tx.db.Follower.NamedQueryContext(ContextWithTx(ctx, tx), query, arg)
+}
+
+// OnCommit registers fn to be called after the transaction is committed.
+// It is never called if the transaction is rolled back
+func (tx *Tx) OnCommit(fn func()) {
+	tx.hooksMu.Lock()
+	defer tx.hooksMu.Unlock()
+	tx.onCommit = append(tx.onCommit, fn)
+}
+
+// OnRollback registers fn to be called after the transaction is rolled back,
+// including when the commit fails
+func (tx *Tx) OnRollback(fn func()) {
+	tx.hooksMu.Lock()
+	defer tx.hooksMu.Unlock()
+	tx.onRollback = append(tx.onRollback, fn)
+}
+
+// Commit commits the transaction, or releases the savepoint of a nested transaction.
+// OnCommit callbacks are called after a successful commit, and OnRollback callbacks after a failed one,
+// including when the transaction was already rolled back by database/sql because its context is done
+func (tx *Tx) Commit() error {
+	if tx.parent != nil {
+		if err := tx.releaseSavepoint(); err != nil {
+			return err
+		}
+		// the nested transaction outcome now follows its parent
+		onCommit, onRollback := tx.takeHooks()
+		tx.parent.hooksMu.Lock()
+		tx.parent.onCommit = append(tx.parent.onCommit, onCommit...)
+		tx.parent.onRollback = append(tx.parent.onRollback, onRollback...)
+		tx.parent.hooksMu.Unlock()
+		return nil
+	}
+
+	err := tx.Tx.Commit()
+	onCommit, onRollback := tx.takeHooks()
+	if err != nil {
+		runTxHooks("rollback", onRollback)
+		return err
+	}
+	runTxHooks("commit", onCommit)
+	return nil
+}
+
+// Rollback aborts the transaction, or rolls back to the savepoint of a nested transaction.
+// OnRollback callbacks are called after the rollback, even if database/sql already rolled back the transaction
+// because its context is done
+func (tx *Tx) Rollback() error {
+	if tx.parent != nil {
+		err := tx.rollbackToSavepoint()
+		_, onRollback := tx.takeHooks()
+		if err != nil {
+			// failing to roll back to savepoint usually means the parent is aborted as well
+			tx.parent.OnRollback(func() { runTxHooks("rollback", onRollback) })
+			return err
+		}
+		runTxHooks("rollback", onRollback)
+		return nil
+	}
+
+	err := tx.Tx.Rollback()
+	_, onRollback := tx.takeHooks()
+	runTxHooks("rollback", onRollback)
+	return err
+}
+
+// takeHooks returns the registered callbacks and clears them, so they are called at most once
+func (tx *Tx) takeHooks() (onCommit, onRollback []func()) {
+	tx.hooksMu.Lock()
+	defer tx.hooksMu.Unlock()
+	onCommit, onRollback = tx.onCommit, tx.onRollback
+	tx.onCommit, tx.onRollback = nil, nil
+	return onCommit, onRollback
+}
+
+// runTxHooks calls every callback, a panic in the callback is recovered and logged
+func runTxHooks(outcome string, hooks []func()) {
+	for _, fn := range hooks {
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					log.Errorf("sqldb: recovered panic in after %s callback of transaction: %v", outcome, p)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+// WithTx runs fn inside a transaction on master DB.
+//
+// The transaction is committed when fn returns nil, and rolled back when fn returns an error or panics.
+// The panic is re-panicked after the rollback.
+//
+// If ctx already carries a transaction, fn runs in a nested transaction instead, see Tx.Savepoint.
+// The nested transaction is released on success or rolled back alone on failure,
+// and the outer transaction decides whether everything is committed. opts is ignored in this case.
+//
+// The ctx given to WithTx doesn't carry tx, so calls inside fn that should join tx must use tx.Context():
+//
+//	err := db.WithTx(ctx, nil, func(tx *sqldb.Tx) error {
+//		return db.WithTx(tx.Context(), nil, func(nested *sqldb.Tx) error { ... })
+//	})
+func (db *DB) WithTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *Tx) error) error {
+	return db.runTx(ctx, opts, func(_ context.Context, tx *Tx) error {
+		return fn(tx)
+	})
+}
+
+// runTx runs fn inside a transaction or a nested transaction, see WithTx.
+// The context given to fn carries the transaction and its span, it is also returned by Tx.Context
+func (db *DB) runTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, tx *Tx) error) (err error) {
+	// the transaction is in-flight until it is committed or rolled back, so Shutdown waits for it.
+	// nested transaction joins its parent, so it still runs while DB is closing
+	if !db.enterOp(ctx) {
+		return ErrClosed
+	}
+	defer db.inflight.exit()
+
+	parent, nested := TxFromContext(ctx)
+
+	spanName := "sql.transaction"
+	if nested {
+		spanName = "sql.savepoint"
+	}
+	ctx, span := db.startSpan(ctx, spanName)
+	defer func() { endSpan(span, err) }()
+
+	var tx *Tx
+	if nested {
+		tx, err = parent.Savepoint(ctx)
+	} else {
+		tx, err = db.beginTx(ctx, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			rollback(tx)
+			span.SetError(fmt.Errorf("panic: %v", p))
+			panic(p)
+		}
+	}()
+
+	ctx = ContextWithTx(ctx, tx)
+	tx.ctx = ctx
+
+	if err := fn(ctx, tx); err != nil {
+		rollback(tx)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		// database/sql rolls back the transaction by itself once ctx is done, the cause is more useful than ErrTxDone
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RunInTx runs fn inside a transaction on master DB like WithTx, with the transaction carried by the context given to fn.
+// Every Master and Follower context method of DB called with that context joins the transaction,
+// so repositories share one unit of work without passing the transaction around.
+//
+//	err := db.RunInTx(ctx, nil, func(ctx context.Context) error {
+//		if err := orderRepo.Create(ctx, order); err != nil {
+//			return err
+//		}
+//		return stockRepo.Reserve(ctx, order.Items)
+//	})
+func (db *DB) RunInTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error {
+	return db.runTx(ctx, opts, func(ctx context.Context, _ *Tx) error {
+		return fn(ctx)
+	})
+}
+
+// WithRetryTx runs fn inside a transaction on master DB like WithTx, and re-runs the whole transaction
+// when it fails because of serialization failure or deadlock, following the retry policy.
+//
+// fn may be called more than once, so it must not have side effect outside the transaction.
+// If ctx already carries a transaction, it is not retried as only the outer transaction can be re-run
+func (db *DB) WithRetryTx(ctx context.Context, opts *sql.TxOptions, policy RetryPolicy, fn func(tx *Tx) error) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return db.WithTx(ctx, opts, fn)
+	}
+	policy = policy.withDefaults(defaultTxRetryPolicy)
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		err := db.WithTx(ctx, opts, fn)
+		if err == nil || !IsRetryableTxError(err) || attempt >= policy.MaxAttempts {
+			return err
+		}
+
+		delay := policy.backoff(attempt)
+		if policy.Deadline > 0 && time.Since(start)+delay > policy.Deadline {
+			return err
+		}
+		log.Warnf("sqldb: transaction failed with retryable error %s, retrying in %s. Attempt: %d", err.Error(), delay, attempt)
+
+		if sleepErr := sleepContext(ctx, delay); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+// IsRetryableTxError reports whether err is a serialization failure or a deadlock,
+// so the transaction may succeed if it is retried from the beginning
+func IsRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "40001", // serialization_failure
+			"40P01": // deadlock_detected
+			return true
+		}
+		return false
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1213, // ER_LOCK_DEADLOCK
+			1205: // ER_LOCK_WAIT_TIMEOUT
+			return true
+		}
+	}
+	return false
+}
+
+func (db *DB) beginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	ctx, done, ok := db.startOp(ctx, db.master.queryInfo(OpBegin, "", nil))
+	if !ok {
+		return nil, ErrClosed
+	}
+	tx, err := db.master.BeginTxx(ctx, opts)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, db: db, driver: db.driver}, nil
+}
+
+// rollback the transaction or nested transaction, the error is only logged as the caller already has an error to return
+func rollback(tx *Tx) {
+	if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		log.Errorf("sqldb: failed to rollback transaction with error %s", err.Error())
+	}
+}
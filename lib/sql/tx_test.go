@@ -0,0 +1,247 @@
+package sql_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sqldb "github.com/kecci/go-toolkit/lib/sql"
+	"github.com/kecci/go-toolkit/lib/sql/sqltest"
+)
+
+var errTest = errors.New("test error")
+
+// outcome records the callbacks run for a transaction
+type outcome struct {
+	committed  bool
+	rolledBack bool
+}
+
+func (o *outcome) register(tx *sqldb.Tx) {
+	tx.OnCommit(func() { o.committed = true })
+	tx.OnRollback(func() { o.rolledBack = true })
+}
+
+func TestWithTxCommit(t *testing.T) {
+	db, mock := sqltest.NewDB(t, "postgres")
+	mock.ExpectBegin()
+	mock.ExpectExec(sqltest.Exact("UPDATE users SET name = $1")).WithArgs("a")
+	mock.ExpectCommit()
+
+	var o outcome
+	err := db.WithTx(context.Background(), nil, func(tx *sqldb.Tx) error {
+		o.register(tx)
+		_, err := tx.Exec("UPDATE users SET name = $1", "a")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !o.committed || o.rolledBack {
+		t.Errorf("expected only OnCommit to run, got %+v", o)
+	}
+}
+
+func TestWithTxRollbackOnError(t *testing.T) {
+	db, mock := sqltest.NewDB(t, "postgres")
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	var o outcome
+	err := db.WithTx(context.Background(), nil, func(tx *sqldb.Tx) error {
+		o.register(tx)
+		return errTest
+	})
+	if err != errTest {
+		t.Fatalf("expected error of fn, got %v", err)
+	}
+	if o.committed || !o.rolledBack {
+		t.Errorf("expected only OnRollback to run, got %+v", o)
+	}
+}
+
+func TestWithTxRollbackOnPanic(t *testing.T) {
+	db, mock := sqltest.NewDB(t, "postgres")
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	var o outcome
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("expected the panic to be re-panicked, got %v", p)
+			}
+		}()
+		db.WithTx(context.Background(), nil, func(tx *sqldb.Tx) error {
+			o.register(tx)
+			panic("boom")
+		})
+	}()
+	if o.committed || !o.rolledBack {
+		t.Errorf("expected only OnRollback to run, got %+v", o)
+	}
+}
+
+func TestRunInTxNestedRollback(t *testing.T) {
+	db, mock := sqltest.NewDB(t, "postgres")
+	mock.ExpectBegin()
+	mock.ExpectExec(sqltest.Exact("SAVEPOINT sp_1"))
+	mock.ExpectExec(sqltest.Exact("ROLLBACK TO SAVEPOINT sp_1"))
+	mock.ExpectCommit()
+
+	var outer, inner outcome
+	err := db.RunInTx(context.Background(), nil, func(ctx context.Context) error {
+		tx, _ := sqldb.TxFromContext(ctx)
+		outer.register(tx)
+
+		err := db.RunInTx(ctx, nil, func(ctx context.Context) error {
+			tx, _ := sqldb.TxFromContext(ctx)
+			inner.register(tx)
+			return errTest
+		})
+		if err != errTest {
+			t.Errorf("expected error of nested fn, got %v", err)
+		}
+		if !inner.rolledBack {
+			t.Error("expected OnRollback of nested transaction to run right after its rollback")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !outer.committed || outer.rolledBack || inner.committed {
+		t.Errorf("expected outer to commit alone, got outer %+v and inner %+v", outer, inner)
+	}
+}
+
+func TestRunInTxNestedCallbacksFollowParent(t *testing.T) {
+	tests := []struct {
+		name     string
+		outerErr error
+		expected outcome
+	}{
+		{name: "parent committed", expected: outcome{committed: true}},
+		{name: "parent rolled back", outerErr: errTest, expected: outcome{rolledBack: true}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := sqltest.NewDB(t, "postgres")
+			mock.ExpectBegin()
+			mock.ExpectExec(sqltest.Exact("SAVEPOINT sp_1"))
+			mock.ExpectExec(sqltest.Exact("RELEASE SAVEPOINT sp_1"))
+			if tt.outerErr == nil {
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			var inner outcome
+			db.RunInTx(context.Background(), nil, func(ctx context.Context) error {
+				err := db.RunInTx(ctx, nil, func(ctx context.Context) error {
+					tx, _ := sqldb.TxFromContext(ctx)
+					inner.register(tx)
+					return nil
+				})
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				if inner.committed || inner.rolledBack {
+					t.Error("expected callbacks of nested transaction to wait for the parent")
+				}
+				return tt.outerErr
+			})
+			if inner != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, inner)
+			}
+		})
+	}
+}
+
+func TestWithTxContextCanceled(t *testing.T) {
+	tests := []struct {
+		name  string
+		fnErr error
+		err   error
+	}{
+		{name: "fn returns error", fnErr: errTest, err: errTest},
+		{name: "fn returns nil", err: context.Canceled},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := sqltest.NewDB(t, "postgres")
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var o outcome
+			err := db.WithTx(ctx, nil, func(tx *sqldb.Tx) error {
+				o.register(tx)
+				cancel()
+				// database/sql rolls back in background once ctx is done, wait for it like a slow fn would
+				waitExpectations(mock)
+				return tt.fnErr
+			})
+			if err != tt.err {
+				t.Errorf("expected error %v, got %v", tt.err, err)
+			}
+			if o.committed || !o.rolledBack {
+				t.Errorf("expected only OnRollback to run, got %+v", o)
+			}
+		})
+	}
+}
+
+// waitExpectations waits a while for the expectations met in background
+func waitExpectations(mock *sqltest.Mock) {
+	deadline := time.Now().Add(time.Second)
+	for mock.ExpectationsWereMet() != nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCloseWaitsForTransaction(t *testing.T) {
+	db, mock := sqltest.NewDB(t, "postgres")
+	mock.ExpectBegin()
+	mock.ExpectExec(sqltest.Exact("DELETE FROM users"))
+	mock.ExpectCommit()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	txErr := make(chan error, 1)
+	go func() {
+		txErr <- db.RunInTx(context.Background(), nil, func(ctx context.Context) error {
+			close(started)
+			<-release
+			_, err := db.Master.ExecContext(ctx, "DELETE FROM users")
+			return err
+		})
+	}()
+	<-started
+
+	closed := make(chan error, 1)
+	go func() { closed <- db.Close() }()
+
+	select {
+	case err := <-closed:
+		t.Fatalf("expected Close to wait for the transaction, returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := db.WithTx(context.Background(), nil, func(*sqldb.Tx) error { return nil }); err != sqldb.ErrClosed {
+		t.Errorf("expected new transaction to fail with ErrClosed, got %v", err)
+	}
+
+	close(release)
+	if err := <-txErr; err != nil {
+		t.Errorf("expected the in-flight transaction to finish, got %v", err)
+	}
+	if err := <-closed; err != nil {
+		t.Errorf("unexpected close error %v", err)
+	}
+}